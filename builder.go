@@ -2,28 +2,15 @@ package launchctlutil
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
-	"strconv"
+	"path"
+	"time"
 )
 
 const (
-	oneIndent    = "    "
-	twoIndents   = oneIndent + oneIndent
-	threeIndents = twoIndents + oneIndent
-
-	openPlist   = "<plist version=\"1.0\">\n"
-	closePlist  = "</plist>\n"
-	openKey     = "<key>"
-	closeKey    = "</key>\n"
-	openDict    = "<dict>\n"
-	closeDict   = "</dict>\n"
-	openArray   = "<array>\n"
-	closeArray  = "</array>\n"
-	openString  = "<string>"
-	closeString = "</string>\n"
-	openInt     = "<integer>"
-	closeInt    = "</integer>\n"
-	newLine     = "\n"
+	minNice = -20
+	maxNice = 20
 )
 
 // ConfigurationBuilder is used to build a new launchd service Configuration.
@@ -47,6 +34,15 @@ type ConfigurationBuilder interface {
 	// SetCommand sets the command to execute.
 	SetCommand(command string) ConfigurationBuilder
 
+	// SetProgram sets the path of the executable to run. When it is
+	// set, the command set by SetCommand is only used as argv[0]
+	// rather than as the executable's path.
+	SetProgram(filePath string) ConfigurationBuilder
+
+	// SetEnableGlobbing sets whether or not launchd expands wildcards
+	// (e.g., "*" and "~") in the command's arguments.
+	SetEnableGlobbing(enabled bool) ConfigurationBuilder
+
 	// AddEnvironmentVariable adds an environment variable with
 	// a given value.
 	AddEnvironmentVariable(name string, value string) ConfigurationBuilder
@@ -63,6 +59,11 @@ type ConfigurationBuilder interface {
 	// and SetStandardOutPath().
 	SetLogParentPath(logParentPath string) ConfigurationBuilder
 
+	// SetSplitLogFiles sets whether or not SetLogParentPath() saves
+	// stdout and stderr to separate files. The files are named
+	// "(launchd-label).out.log" and "(launchd-label).err.log".
+	SetSplitLogFiles(enabled bool) ConfigurationBuilder
+
 	// SetStandardErrorPath sets the file path where stderr
 	// output will be saved to.
 	//
@@ -75,22 +76,131 @@ type ConfigurationBuilder interface {
 	// This setting is ignored if SetLogParentPath() is used.
 	SetStandardOutPath(filePath string) ConfigurationBuilder
 
+	// SetStandardInPath sets the file path that stdin will be
+	// read from.
+	SetStandardInPath(filePath string) ConfigurationBuilder
+
 	// SetKind sets the type.
 	SetKind(kind Kind) ConfigurationBuilder
 
+	// SetBundlePath sets the path to the application bundle (e.g.,
+	// "/Applications/Example.app") that contains the service. It is
+	// required by the BundledAgent and BundledDaemon kinds.
+	SetBundlePath(appBundlePath string) ConfigurationBuilder
+
 	// SetStartInterval sets the start interval in seconds.
 	SetStartInterval(seconds int) ConfigurationBuilder
 
+	// SetStartIntervalDuration sets the start interval. The duration
+	// is rounded to the nearest second and must be at least one second.
+	SetStartIntervalDuration(interval time.Duration) ConfigurationBuilder
+
+	// SetThrottleInterval sets the minimum amount of time between
+	// starts of the service. launchd defaults to 10 seconds. The
+	// duration is rounded to the nearest second and must not be
+	// less than one second unless it is zero.
+	SetThrottleInterval(interval time.Duration) ConfigurationBuilder
+
+	// SetExitTimeOut sets the amount of time launchd waits between
+	// sending SIGTERM and SIGKILL when stopping the service. Zero
+	// means launchd waits forever. The duration is rounded to the
+	// nearest second and must not be less than one second unless
+	// it is zero.
+	SetExitTimeOut(timeout time.Duration) ConfigurationBuilder
+
+	// SetTimeOut sets the amount of time the service may be idle
+	// before launchd stops it. The duration is rounded to the nearest
+	// second and must not be less than one second unless it is zero.
+	SetTimeOut(timeout time.Duration) ConfigurationBuilder
+
+	// SetLaunchOnlyOnce sets whether or not the service is started
+	// only once. launchd will not start it again, even after it exits.
+	SetLaunchOnlyOnce(enabled bool) ConfigurationBuilder
+
 	// SetStartCalendarIntervalMinute sets the minute of each hour
 	// that the command will be executed. For example, setting the
 	// minute to 10 will run the command at the 10th minute of each
 	// hour: 01:10, 02:10, 03:10, and so on.
 	SetStartCalendarIntervalMinute(minuteOfEachHour int) ConfigurationBuilder
 
+	// AddStartCalendarInterval adds a calendar interval that the
+	// command will be executed at. The command is executed when any
+	// of the intervals match.
+	AddStartCalendarInterval(interval CalendarInterval) ConfigurationBuilder
+
 	// SetRunAtLoad sets whether or not the service will start
 	// when it is loaded.
 	SetRunAtLoad(enabled bool) ConfigurationBuilder
 
+	// SetKeepAlive sets whether or not launchd keeps the service
+	// running regardless of the conditions under which it exits.
+	//
+	// This setting overrides the settings of SetKeepAliveConditions().
+	SetKeepAlive(enabled bool) ConfigurationBuilder
+
+	// SetKeepAliveConditions sets the conditions under which launchd
+	// keeps the service running.
+	//
+	// This setting overrides the settings of SetKeepAlive().
+	SetKeepAliveConditions(conditions KeepAliveConditions) ConfigurationBuilder
+
+	// AddWatchPath adds a path that will start the service when
+	// it is modified. The path must be absolute.
+	AddWatchPath(filePath string) ConfigurationBuilder
+
+	// AddQueueDirectory adds a directory that will keep the service
+	// running for as long as the directory is not empty. The path
+	// must be absolute.
+	AddQueueDirectory(dirPath string) ConfigurationBuilder
+
+	// SetStartOnMount sets whether or not the service will start
+	// every time a filesystem is mounted.
+	SetStartOnMount(enabled bool) ConfigurationBuilder
+
+	// AddSocket adds a socket that launchd listens on for the service.
+	// launchd starts the service when the socket is used. Sockets added
+	// with the same name are grouped into an array under that name.
+	AddSocket(name string, socket Socket) ConfigurationBuilder
+
+	// AddMachService adds a Mach service that the service advertises
+	// in the bootstrap namespace.
+	AddMachService(name string, service MachService) ConfigurationBuilder
+
+	// AddLaunchEvent adds an XPC event that starts the service. The
+	// stream is the event stream (e.g., "com.apple.iokit.matching"),
+	// and the name identifies the event within the stream. The
+	// descriptor's contents depend on the stream.
+	//
+	// Example:
+	//	AddLaunchEvent("com.apple.iokit.matching", "com.example.device-attach",
+	//		launchctlutil.PlistDict{
+	//			{Key: "idVendor", Value: launchctlutil.PlistInteger(1452)},
+	//			{Key: "IOProviderClass", Value: launchctlutil.PlistString("IOUSBDevice")},
+	//			{Key: "IOMatchLaunchStream", Value: launchctlutil.PlistBool(true)},
+	//		})
+	AddLaunchEvent(stream string, name string, descriptor PlistDict) ConfigurationBuilder
+
+	// AddLimitLoadToSessionType adds a session type that the service
+	// is loaded in. The service is only loaded in the added session
+	// types. Adding a single session type writes it as a string,
+	// while adding several writes them as an array.
+	AddLimitLoadToSessionType(sessionType SessionType) ConfigurationBuilder
+
+	// AddLimitLoadToHost adds a host name that the service is loaded on.
+	// The service is only loaded on the added hosts.
+	AddLimitLoadToHost(hostName string) ConfigurationBuilder
+
+	// AddLimitLoadFromHost adds a host name that the service is not
+	// loaded on.
+	AddLimitLoadFromHost(hostName string) ConfigurationBuilder
+
+	// SetDisabled sets whether or not launchd skips loading the service.
+	SetDisabled(disabled bool) ConfigurationBuilder
+
+	// SetSessionCreate sets whether or not the service is started in
+	// a separate security audit session.
+	SetSessionCreate(enabled bool) ConfigurationBuilder
+
 	// SetUserName sets whether the service should run as a specific
 	// user (by username).
 	SetUserName(userName string) ConfigurationBuilder
@@ -106,6 +216,61 @@ type ConfigurationBuilder interface {
 	// SetUmask sets the umask for the service.
 	SetUmask(umask int) ConfigurationBuilder
 
+	// SetWorkingDirectory sets the directory that the service's
+	// process starts in. The path must be absolute.
+	SetWorkingDirectory(dirPath string) ConfigurationBuilder
+
+	// SetRootDirectory sets the directory that launchd chroot(2)s
+	// to before starting the service. The path must be absolute.
+	SetRootDirectory(dirPath string) ConfigurationBuilder
+
+	// SetNice sets the scheduling priority of the service's process.
+	// The value must be between -20 and 20.
+	SetNice(nice int) ConfigurationBuilder
+
+	// SetProcessType sets the resource limits and scheduling policy
+	// that macOS applies to the service's process.
+	SetProcessType(processType ProcessType) ConfigurationBuilder
+
+	// SetLowPriorityIO sets whether or not the service's file system
+	// I/O is throttled.
+	SetLowPriorityIO(enabled bool) ConfigurationBuilder
+
+	// SetLowPriorityBackgroundIO sets whether or not the service's file
+	// system I/O is throttled when the process is in the background.
+	SetLowPriorityBackgroundIO(enabled bool) ConfigurationBuilder
+
+	// SetAbandonProcessGroup sets whether or not launchd leaves the
+	// processes in the service's process group running when the
+	// service exits.
+	SetAbandonProcessGroup(enabled bool) ConfigurationBuilder
+
+	// SetEnablePressuredExit sets whether or not the system may kill
+	// the service when it is clean and the system is under memory
+	// pressure.
+	SetEnablePressuredExit(enabled bool) ConfigurationBuilder
+
+	// SetSoftResourceLimit sets the soft limit of a resource. The
+	// soft limit cannot exceed the resource's hard limit.
+	SetSoftResourceLimit(resource Resource, value int) ConfigurationBuilder
+
+	// SetHardResourceLimit sets the hard limit of a resource.
+	SetHardResourceLimit(resource Resource, value int) ConfigurationBuilder
+
+	// SetKey sets a key that does not have a typed setter. The value
+	// is written using the same encoder as the typed setters' keys.
+	// Build returns an error if the key is also set by a typed setter.
+	//
+	// Example:
+	//	SetKey("AssociatedBundleIdentifiers", launchctlutil.PlistArray{
+	//		launchctlutil.PlistString("com.example.app"),
+	//	})
+	SetKey(name string, value PlistValue) ConfigurationBuilder
+
+	// SetPlistFormat sets the format of the Configuration's property
+	// list, such as its indentation and key order.
+	SetPlistFormat(format PlistFormat) ConfigurationBuilder
+
 	// Build returns the resulting service Configuration.
 	Build() (Configuration, error)
 }
@@ -113,25 +278,74 @@ type ConfigurationBuilder interface {
 type configurationBuilder struct {
 	label                             string
 	command                           string
-	environmentVariables              string
-	arguments                         string
-	lines                             string
+	environmentVariables              PlistDict
+	arguments                         []string
 	logParentPath                     string
 	stderrLogFilePath                 string
 	stdoutLogFilePath                 string
-	configurationFilePath             string
+	splitLogFiles                     bool
+	stdinFilePath                     string
+	program                           string
+	enableGlobbing                    bool
+	isEnableGlobbingSet               bool
 	kind                              Kind
-	startIntervalSeconds              int
+	bundlePath                        string
+	startInterval                     time.Duration
+	throttleInterval                  time.Duration
+	isThrottleIntervalSet             bool
+	exitTimeOut                       time.Duration
+	isExitTimeOutSet                  bool
+	timeOut                           time.Duration
+	isTimeOutSet                      bool
+	launchOnlyOnce                    bool
+	isLaunchOnlyOnceSet               bool
 	startCalendarIntervalMinuteOfHour int
 	isStartCalendarIntervalMinuteSet  bool
+	startCalendarIntervals            []CalendarInterval
 	runAtLoad                         bool
 	isRunAtLoadSet                    bool
+	keepAlive                         bool
+	isKeepAliveSet                    bool
+	keepAliveConditions               *KeepAliveConditions
+	watchPaths                        []string
+	queueDirectories                  []string
+	startOnMount                      bool
+	isStartOnMountSet                 bool
+	socketNames                       []string
+	sockets                           map[string][]Socket
+	machServices                      PlistDict
+	launchEventStreams                []string
+	launchEvents                      map[string]PlistDict
+	customKeys                        PlistDict
+	plistFormat                       PlistFormat
+	limitLoadToSessionTypes           []SessionType
+	limitLoadToHosts                  []string
+	limitLoadFromHosts                []string
+	disabled                          bool
+	isDisabledSet                     bool
+	sessionCreate                     bool
+	isSessionCreateSet                bool
 	userName                          string
 	groupName                         string
 	initGroups                        bool
 	isInitGroupsSet                   bool
 	umask                             int
 	isUmaskSet                        bool
+	workingDirectory                  string
+	rootDirectory                     string
+	nice                              int
+	isNiceSet                         bool
+	processType                       ProcessType
+	lowPriorityIO                     bool
+	isLowPriorityIOSet                bool
+	lowPriorityBackgroundIO           bool
+	isLowPriorityBackgroundIOSet      bool
+	abandonProcessGroup               bool
+	isAbandonProcessGroupSet          bool
+	enablePressuredExit               bool
+	isEnablePressuredExitSet          bool
+	softResourceLimits                map[Resource]int
+	hardResourceLimits                map[Resource]int
 }
 
 // NewConfigurationBuilder creates a new instance of a ConfigurationBuilder.
@@ -149,15 +363,24 @@ func (o *configurationBuilder) SetCommand(command string) ConfigurationBuilder {
 	return o
 }
 
+func (o *configurationBuilder) SetProgram(filePath string) ConfigurationBuilder {
+	o.program = filePath
+	return o
+}
+
+func (o *configurationBuilder) SetEnableGlobbing(enabled bool) ConfigurationBuilder {
+	o.enableGlobbing = enabled
+	o.isEnableGlobbingSet = true
+	return o
+}
+
 func (o *configurationBuilder) AddEnvironmentVariable(name string, value string) ConfigurationBuilder {
-	o.environmentVariables = concat(o.environmentVariables, "            ", openKey, name, closeKey,
-		threeIndents, openString, value, closeString)
+	o.environmentVariables = append(o.environmentVariables, PlistEntry{Key: name, Value: PlistString(value)})
 	return o
 }
 
 func (o *configurationBuilder) AddArgument(value string) ConfigurationBuilder {
-	o.arguments = concat(o.arguments,
-		threeIndents, openString, value, closeString)
+	o.arguments = append(o.arguments, value)
 	return o
 }
 
@@ -166,6 +389,11 @@ func (o *configurationBuilder) SetLogParentPath(logParentPath string) Configurat
 	return o
 }
 
+func (o *configurationBuilder) SetSplitLogFiles(enabled bool) ConfigurationBuilder {
+	o.splitLogFiles = enabled
+	return o
+}
+
 func (o *configurationBuilder) SetStandardErrorPath(filePath string) ConfigurationBuilder {
 	o.stderrLogFilePath = filePath
 	return o
@@ -176,13 +404,52 @@ func (o *configurationBuilder) SetStandardOutPath(filePath string) Configuration
 	return o
 }
 
+func (o *configurationBuilder) SetStandardInPath(filePath string) ConfigurationBuilder {
+	o.stdinFilePath = filePath
+	return o
+}
+
 func (o *configurationBuilder) SetKind(kind Kind) ConfigurationBuilder {
 	o.kind = kind
 	return o
 }
 
+func (o *configurationBuilder) SetBundlePath(appBundlePath string) ConfigurationBuilder {
+	o.bundlePath = appBundlePath
+	return o
+}
+
 func (o *configurationBuilder) SetStartInterval(seconds int) ConfigurationBuilder {
-	o.startIntervalSeconds = seconds
+	o.startInterval = time.Duration(seconds) * time.Second
+	return o
+}
+
+func (o *configurationBuilder) SetStartIntervalDuration(interval time.Duration) ConfigurationBuilder {
+	o.startInterval = interval
+	return o
+}
+
+func (o *configurationBuilder) SetThrottleInterval(interval time.Duration) ConfigurationBuilder {
+	o.throttleInterval = interval
+	o.isThrottleIntervalSet = true
+	return o
+}
+
+func (o *configurationBuilder) SetExitTimeOut(timeout time.Duration) ConfigurationBuilder {
+	o.exitTimeOut = timeout
+	o.isExitTimeOutSet = true
+	return o
+}
+
+func (o *configurationBuilder) SetTimeOut(timeout time.Duration) ConfigurationBuilder {
+	o.timeOut = timeout
+	o.isTimeOutSet = true
+	return o
+}
+
+func (o *configurationBuilder) SetLaunchOnlyOnce(enabled bool) ConfigurationBuilder {
+	o.launchOnlyOnce = enabled
+	o.isLaunchOnlyOnceSet = true
 	return o
 }
 
@@ -192,12 +459,104 @@ func (o *configurationBuilder) SetStartCalendarIntervalMinute(minuteOfEachHour i
 	return o
 }
 
+func (o *configurationBuilder) AddStartCalendarInterval(interval CalendarInterval) ConfigurationBuilder {
+	o.startCalendarIntervals = append(o.startCalendarIntervals, interval)
+	return o
+}
+
 func (o *configurationBuilder) SetRunAtLoad(enabled bool) ConfigurationBuilder {
 	o.runAtLoad = enabled
 	o.isRunAtLoadSet = true
 	return o
 }
 
+func (o *configurationBuilder) SetKeepAlive(enabled bool) ConfigurationBuilder {
+	o.keepAlive = enabled
+	o.isKeepAliveSet = true
+	o.keepAliveConditions = nil
+	return o
+}
+
+func (o *configurationBuilder) SetKeepAliveConditions(conditions KeepAliveConditions) ConfigurationBuilder {
+	o.keepAliveConditions = &conditions
+	o.isKeepAliveSet = false
+	return o
+}
+
+func (o *configurationBuilder) AddWatchPath(filePath string) ConfigurationBuilder {
+	o.watchPaths = append(o.watchPaths, filePath)
+	return o
+}
+
+func (o *configurationBuilder) AddQueueDirectory(dirPath string) ConfigurationBuilder {
+	o.queueDirectories = append(o.queueDirectories, dirPath)
+	return o
+}
+
+func (o *configurationBuilder) SetStartOnMount(enabled bool) ConfigurationBuilder {
+	o.startOnMount = enabled
+	o.isStartOnMountSet = true
+	return o
+}
+
+func (o *configurationBuilder) AddSocket(name string, socket Socket) ConfigurationBuilder {
+	if o.sockets == nil {
+		o.sockets = make(map[string][]Socket)
+	}
+
+	if _, exists := o.sockets[name]; !exists {
+		o.socketNames = append(o.socketNames, name)
+	}
+
+	o.sockets[name] = append(o.sockets[name], socket)
+	return o
+}
+
+func (o *configurationBuilder) AddMachService(name string, service MachService) ConfigurationBuilder {
+	o.machServices = append(o.machServices, PlistEntry{Key: name, Value: service.plistValue()})
+	return o
+}
+
+func (o *configurationBuilder) AddLaunchEvent(stream string, name string, descriptor PlistDict) ConfigurationBuilder {
+	if o.launchEvents == nil {
+		o.launchEvents = make(map[string]PlistDict)
+	}
+
+	if _, exists := o.launchEvents[stream]; !exists {
+		o.launchEventStreams = append(o.launchEventStreams, stream)
+	}
+
+	o.launchEvents[stream] = append(o.launchEvents[stream], PlistEntry{Key: name, Value: descriptor})
+	return o
+}
+
+func (o *configurationBuilder) AddLimitLoadToSessionType(sessionType SessionType) ConfigurationBuilder {
+	o.limitLoadToSessionTypes = append(o.limitLoadToSessionTypes, sessionType)
+	return o
+}
+
+func (o *configurationBuilder) AddLimitLoadToHost(hostName string) ConfigurationBuilder {
+	o.limitLoadToHosts = append(o.limitLoadToHosts, hostName)
+	return o
+}
+
+func (o *configurationBuilder) AddLimitLoadFromHost(hostName string) ConfigurationBuilder {
+	o.limitLoadFromHosts = append(o.limitLoadFromHosts, hostName)
+	return o
+}
+
+func (o *configurationBuilder) SetDisabled(disabled bool) ConfigurationBuilder {
+	o.disabled = disabled
+	o.isDisabledSet = true
+	return o
+}
+
+func (o *configurationBuilder) SetSessionCreate(enabled bool) ConfigurationBuilder {
+	o.sessionCreate = enabled
+	o.isSessionCreateSet = true
+	return o
+}
+
 func (o *configurationBuilder) SetUserName(userName string) ConfigurationBuilder {
 	o.userName = userName
 	return o
@@ -220,99 +579,470 @@ func (o *configurationBuilder) SetUmask(umask int) ConfigurationBuilder {
 	return o
 }
 
+func (o *configurationBuilder) SetWorkingDirectory(dirPath string) ConfigurationBuilder {
+	o.workingDirectory = dirPath
+	return o
+}
+
+func (o *configurationBuilder) SetRootDirectory(dirPath string) ConfigurationBuilder {
+	o.rootDirectory = dirPath
+	return o
+}
+
+func (o *configurationBuilder) SetNice(nice int) ConfigurationBuilder {
+	o.nice = nice
+	o.isNiceSet = true
+	return o
+}
+
+func (o *configurationBuilder) SetProcessType(processType ProcessType) ConfigurationBuilder {
+	o.processType = processType
+	return o
+}
+
+func (o *configurationBuilder) SetLowPriorityIO(enabled bool) ConfigurationBuilder {
+	o.lowPriorityIO = enabled
+	o.isLowPriorityIOSet = true
+	return o
+}
+
+func (o *configurationBuilder) SetLowPriorityBackgroundIO(enabled bool) ConfigurationBuilder {
+	o.lowPriorityBackgroundIO = enabled
+	o.isLowPriorityBackgroundIOSet = true
+	return o
+}
+
+func (o *configurationBuilder) SetAbandonProcessGroup(enabled bool) ConfigurationBuilder {
+	o.abandonProcessGroup = enabled
+	o.isAbandonProcessGroupSet = true
+	return o
+}
+
+func (o *configurationBuilder) SetEnablePressuredExit(enabled bool) ConfigurationBuilder {
+	o.enablePressuredExit = enabled
+	o.isEnablePressuredExitSet = true
+	return o
+}
+
+func (o *configurationBuilder) SetSoftResourceLimit(resource Resource, value int) ConfigurationBuilder {
+	if o.softResourceLimits == nil {
+		o.softResourceLimits = make(map[Resource]int)
+	}
+
+	o.softResourceLimits[resource] = value
+	return o
+}
+
+func (o *configurationBuilder) SetHardResourceLimit(resource Resource, value int) ConfigurationBuilder {
+	if o.hardResourceLimits == nil {
+		o.hardResourceLimits = make(map[Resource]int)
+	}
+
+	o.hardResourceLimits[resource] = value
+	return o
+}
+
+func (o *configurationBuilder) SetKey(name string, value PlistValue) ConfigurationBuilder {
+	for i := range o.customKeys {
+		if o.customKeys[i].Key == name {
+			o.customKeys[i].Value = value
+			return o
+		}
+	}
+
+	o.customKeys = append(o.customKeys, PlistEntry{Key: name, Value: value})
+	return o
+}
+
+func (o *configurationBuilder) SetPlistFormat(format PlistFormat) ConfigurationBuilder {
+	o.plistFormat = format
+	return o
+}
+
 func (o *configurationBuilder) Build() (Configuration, error) {
-	lines := concat("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n",
-		"<!DOCTYPE plist PUBLIC \"-//Apple//DTD PLIST 1.0//EN\" ",
-		"\"http://www.apple.com/DTDs/PropertyList-1.0.dtd\">\n",
-		openPlist,
-		oneIndent, openDict)
+	err := o.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	dict := o.plistDict()
+
+	for _, entry := range o.customKeys {
+		_, isSet := dict.Get(entry.Key)
+		if isSet {
+			return nil, fmt.Errorf("key '%s' is already set by a typed setter", entry.Key)
+		}
+
+		dict = append(dict, entry)
+	}
+
+	return &configuration{
+		label:      o.label,
+		contents:   encodePlistDocumentFormat(dict, o.plistFormat),
+		kind:       o.kind,
+		bundlePath: o.bundlePath,
+	}, nil
+}
+
+// plistDict returns the keys set by the builder's typed setters.
+func (o *configurationBuilder) plistDict() PlistDict {
+	dict := PlistDict{
+		{Key: "Label", Value: PlistString(o.label)},
+	}
 
-	lines = concat(lines, twoIndents, openKey, "Label", closeKey,
-		twoIndents, openString, o.label, closeString)
+	add := func(key string, value PlistValue) {
+		dict = append(dict, PlistEntry{Key: key, Value: value})
+	}
+
+	if o.isDisabledSet {
+		add("Disabled", PlistBool(o.disabled))
+	}
+
+	if len(o.limitLoadToSessionTypes) == 1 {
+		add("LimitLoadToSessionType", PlistString(o.limitLoadToSessionTypes[0]))
+	} else if len(o.limitLoadToSessionTypes) > 1 {
+		sessionTypes := PlistArray{}
+		for _, sessionType := range o.limitLoadToSessionTypes {
+			sessionTypes = append(sessionTypes, PlistString(sessionType))
+		}
+		add("LimitLoadToSessionType", sessionTypes)
+	}
+
+	if len(o.limitLoadToHosts) > 0 {
+		add("LimitLoadToHosts", stringsToPlistArray(o.limitLoadToHosts))
+	}
+
+	if len(o.limitLoadFromHosts) > 0 {
+		add("LimitLoadFromHosts", stringsToPlistArray(o.limitLoadFromHosts))
+	}
 
 	if len(o.environmentVariables) > 0 {
-		lines = concat(lines, twoIndents, openKey, "EnvironmentVariables", closeKey,
-			twoIndents, openDict,
-			o.environmentVariables,
-			twoIndents, closeDict)
+		add("EnvironmentVariables", o.environmentVariables)
 	}
 
 	if len(o.userName) > 0 {
-		lines = concat(lines, twoIndents, openKey, "UserName", closeKey,
-			twoIndents, openString, o.userName, closeString)
+		add("UserName", PlistString(o.userName))
 	}
 
 	if len(o.groupName) > 0 {
-		lines = concat(lines, twoIndents, openKey, "GroupName", closeKey,
-			twoIndents, openString, o.groupName, closeString)
+		add("GroupName", PlistString(o.groupName))
+	}
+
+	if o.isSessionCreateSet {
+		add("SessionCreate", PlistBool(o.sessionCreate))
 	}
 
 	if o.isInitGroupsSet {
-		lines = concat(lines, twoIndents, openKey, "InitGroups", closeKey,
-			twoIndents, boolToXml(o.initGroups), newLine)
+		add("InitGroups", PlistBool(o.initGroups))
 	}
 
 	if o.isUmaskSet {
-		lines = concat(lines, twoIndents, openKey, "Umask", closeKey,
-			twoIndents, openInt, strconv.Itoa(o.umask), closeInt)
+		add("Umask", PlistInteger(o.umask))
+	}
+
+	if len(o.workingDirectory) > 0 {
+		add("WorkingDirectory", PlistString(o.workingDirectory))
+	}
+
+	if len(o.rootDirectory) > 0 {
+		add("RootDirectory", PlistString(o.rootDirectory))
+	}
+
+	if o.isNiceSet {
+		add("Nice", PlistInteger(o.nice))
+	}
+
+	if len(o.processType) > 0 {
+		add("ProcessType", PlistString(o.processType))
+	}
+
+	if o.isLowPriorityIOSet {
+		add("LowPriorityIO", PlistBool(o.lowPriorityIO))
+	}
+
+	if o.isLowPriorityBackgroundIOSet {
+		add("LowPriorityBackgroundIO", PlistBool(o.lowPriorityBackgroundIO))
+	}
+
+	if o.isAbandonProcessGroupSet {
+		add("AbandonProcessGroup", PlistBool(o.abandonProcessGroup))
+	}
+
+	if o.isEnablePressuredExitSet {
+		add("EnablePressuredExit", PlistBool(o.enablePressuredExit))
+	}
+
+	if len(o.softResourceLimits) > 0 {
+		add("SoftResourceLimits", resourceLimitsDict(o.softResourceLimits))
+	}
+
+	if len(o.hardResourceLimits) > 0 {
+		add("HardResourceLimits", resourceLimitsDict(o.hardResourceLimits))
+	}
+
+	if len(o.program) > 0 {
+		add("Program", PlistString(o.program))
 	}
 
 	if len(o.command) > 0 {
-		lines = concat(lines, twoIndents, openKey, "ProgramArguments", closeKey,
-			twoIndents, openArray,
-			threeIndents, openString, o.command, closeString)
+		add("ProgramArguments", stringsToPlistArray(append([]string{o.command}, o.arguments...)))
+	}
 
-		if len(o.arguments) > 0 {
-			lines = concat(lines, o.arguments)
-		}
+	if o.isEnableGlobbingSet {
+		add("EnableGlobbing", PlistBool(o.enableGlobbing))
+	}
 
-		lines = concat(lines, twoIndents, closeArray)
+	if len(o.stdinFilePath) > 0 {
+		add("StandardInPath", PlistString(o.stdinFilePath))
 	}
 
 	if len(o.logParentPath) > 0 {
-		lines = concat(lines, twoIndents, openKey, "StandardOutPath", closeKey,
-			twoIndents, openString, o.logParentPath, "/", o.label, ".log", closeString)
+		stdoutSuffix := ".log"
+		stderrSuffix := ".log"
+		if o.splitLogFiles {
+			stdoutSuffix = ".out.log"
+			stderrSuffix = ".err.log"
+		}
 
-		lines = concat(lines, twoIndents, openKey, "StandardErrorPath", closeKey,
-			twoIndents, openString, o.logParentPath, "/", o.label, ".log", closeString)
+		add("StandardOutPath", PlistString(o.logParentPath+"/"+o.label+stdoutSuffix))
+		add("StandardErrorPath", PlistString(o.logParentPath+"/"+o.label+stderrSuffix))
 	} else {
 		if len(o.stderrLogFilePath) > 0 {
-			lines = concat(lines, twoIndents, openKey, "StandardErrorPath", closeKey,
-				twoIndents, openString, o.stderrLogFilePath, closeString)
+			add("StandardErrorPath", PlistString(o.stderrLogFilePath))
 		}
 
 		if len(o.stdoutLogFilePath) > 0 {
-			lines = concat(lines, twoIndents, openKey, "StandardOutPath", closeKey,
-				twoIndents, openString, o.stdoutLogFilePath, closeString)
+			add("StandardOutPath", PlistString(o.stdoutLogFilePath))
 		}
 	}
 
-	if o.startIntervalSeconds > 0 {
-		lines = concat(lines, twoIndents, openKey, "StartInterval", closeKey,
-			twoIndents, openInt, strconv.Itoa(o.startIntervalSeconds), closeInt)
+	if o.startInterval > 0 {
+		add("StartInterval", PlistInteger(durationToSeconds(o.startInterval)))
+	}
+
+	if o.isThrottleIntervalSet {
+		add("ThrottleInterval", PlistInteger(durationToSeconds(o.throttleInterval)))
+	}
+
+	if o.isExitTimeOutSet {
+		add("ExitTimeOut", PlistInteger(durationToSeconds(o.exitTimeOut)))
+	}
 
+	if o.isTimeOutSet {
+		add("TimeOut", PlistInteger(durationToSeconds(o.timeOut)))
 	}
 
+	if o.isLaunchOnlyOnceSet {
+		add("LaunchOnlyOnce", PlistBool(o.launchOnlyOnce))
+	}
+
+	var calendarIntervals PlistArray
+
 	if o.isStartCalendarIntervalMinuteSet {
-		lines = concat(lines, twoIndents, openKey, "StartCalendarInterval", closeKey,
-			twoIndents, openDict,
-			threeIndents, openKey, "Minute", closeKey,
-			threeIndents, openInt, strconv.Itoa(o.startCalendarIntervalMinuteOfHour), closeInt,
-			twoIndents, closeDict)
+		calendarIntervals = append(calendarIntervals, PlistDict{
+			{Key: "Minute", Value: PlistInteger(o.startCalendarIntervalMinuteOfHour)},
+		})
+	}
+
+	for _, interval := range o.startCalendarIntervals {
+		calendarIntervals = append(calendarIntervals, interval.plistDict())
+	}
+
+	if len(calendarIntervals) == 1 {
+		add("StartCalendarInterval", calendarIntervals[0])
+	} else if len(calendarIntervals) > 1 {
+		add("StartCalendarInterval", calendarIntervals)
+	}
+
+	if len(o.watchPaths) > 0 {
+		add("WatchPaths", stringsToPlistArray(o.watchPaths))
+	}
+
+	if len(o.queueDirectories) > 0 {
+		add("QueueDirectories", stringsToPlistArray(o.queueDirectories))
+	}
+
+	if o.isStartOnMountSet {
+		add("StartOnMount", PlistBool(o.startOnMount))
+	}
+
+	if len(o.socketNames) > 0 {
+		add("Sockets", o.socketsDict())
+	}
+
+	if len(o.machServices) > 0 {
+		add("MachServices", o.machServices)
+	}
+
+	if len(o.launchEventStreams) > 0 {
+		launchEvents := PlistDict{}
+		for _, stream := range o.launchEventStreams {
+			launchEvents = append(launchEvents, PlistEntry{Key: stream, Value: o.launchEvents[stream]})
+		}
+		add("LaunchEvents", launchEvents)
 	}
 
 	if o.isRunAtLoadSet {
-		lines = concat(lines, twoIndents, openKey, "RunAtLoad", closeKey,
-			twoIndents, boolToXml(o.runAtLoad), newLine)
+		add("RunAtLoad", PlistBool(o.runAtLoad))
 	}
 
-	lines = concat(lines, oneIndent, closeDict, closePlist)
+	if o.isKeepAliveSet {
+		add("KeepAlive", PlistBool(o.keepAlive))
+	} else if o.keepAliveConditions != nil {
+		add("KeepAlive", o.keepAliveConditions.plistDict())
+	}
 
-	return &configuration{
-		label:    o.label,
-		contents: lines,
-		kind:     o.kind,
-	}, nil
+	return dict
+}
+
+// validate returns a non-nil error if the builder's settings cannot
+// produce a valid configuration.
+func (o *configurationBuilder) validate() error {
+	if len(o.workingDirectory) > 0 && !path.IsAbs(o.workingDirectory) {
+		return fmt.Errorf("working directory '%s' must be an absolute path", o.workingDirectory)
+	}
+
+	if len(o.rootDirectory) > 0 && !path.IsAbs(o.rootDirectory) {
+		return fmt.Errorf("root directory '%s' must be an absolute path", o.rootDirectory)
+	}
+
+	if o.isNiceSet && (o.nice < minNice || o.nice > maxNice) {
+		return fmt.Errorf("nice value %d must be between %d and %d", o.nice, minNice, maxNice)
+	}
+
+	if o.startInterval < 0 || (o.startInterval > 0 && o.startInterval < time.Second) {
+		return fmt.Errorf("start interval %s must be at least one second", o.startInterval)
+	}
+
+	durations := []struct {
+		name     string
+		duration time.Duration
+	}{
+		{name: "throttle interval", duration: o.throttleInterval},
+		{name: "exit timeout", duration: o.exitTimeOut},
+		{name: "timeout", duration: o.timeOut},
+	}
+
+	for _, d := range durations {
+		if d.duration < 0 || (d.duration > 0 && d.duration < time.Second) {
+			return fmt.Errorf("%s %s must be zero or at least one second", d.name, d.duration)
+		}
+	}
+
+	err := validateResourceLimits(o.softResourceLimits, o.hardResourceLimits)
+	if err != nil {
+		return err
+	}
+
+	for _, sessionType := range o.limitLoadToSessionTypes {
+		switch sessionType {
+		case AquaSession, BackgroundSession, LoginWindowSession, StandardIOSession, SystemSession:
+		default:
+			return fmt.Errorf("unknown session type '%s'", sessionType)
+		}
+	}
+
+	switch o.processType {
+	case "", BackgroundProcess, StandardProcess, AdaptiveProcess, InteractiveProcess:
+	default:
+		return fmt.Errorf("unknown process type '%s'", o.processType)
+	}
+
+	for _, interval := range o.startCalendarIntervals {
+		err := interval.validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, p := range o.watchPaths {
+		if !path.IsAbs(p) {
+			return fmt.Errorf("watch path '%s' must be an absolute path", p)
+		}
+	}
+
+	for _, p := range o.queueDirectories {
+		if !path.IsAbs(p) {
+			return fmt.Errorf("queue directory '%s' must be an absolute path", p)
+		}
+	}
+
+	for _, name := range o.socketNames {
+		for _, socket := range o.sockets[name] {
+			err := socket.validate(name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = validatePlistKeys("MachServices", o.machServices)
+	if err != nil {
+		return err
+	}
+
+	err = validatePlistKeys("SetKey", o.customKeys)
+	if err != nil {
+		return err
+	}
+
+	for _, stream := range o.launchEventStreams {
+		if len(stream) == 0 {
+			return errors.New("launch event stream names cannot be empty")
+		}
+
+		err := validatePlistKeys("LaunchEvents."+stream, o.launchEvents[stream])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// socketsDict returns the value of the Sockets key. A name with a single
+// socket is a dict, while a name with multiple sockets is an array
+// of dicts.
+func (o *configurationBuilder) socketsDict() PlistDict {
+	dict := PlistDict{}
+
+	for _, name := range o.socketNames {
+		listeners := o.sockets[name]
+		if len(listeners) == 1 {
+			dict = append(dict, PlistEntry{Key: name, Value: listeners[0].plistDict()})
+			continue
+		}
+
+		array := PlistArray{}
+		for _, listener := range listeners {
+			array = append(array, listener.plistDict())
+		}
+		dict = append(dict, PlistEntry{Key: name, Value: array})
+	}
+
+	return dict
+}
+
+func stringsToPlistArray(values []string) PlistArray {
+	array := PlistArray{}
+	for _, value := range values {
+		array = append(array, PlistString(value))
+	}
+
+	return array
+}
+
+func intPointer(i int) *int {
+	return &i
+}
+
+func boolPointer(b bool) *bool {
+	return &b
+}
+
+// durationToSeconds returns the duration rounded to the nearest second.
+func durationToSeconds(d time.Duration) int {
+	return int((d + time.Second/2) / time.Second)
 }
 
 func boolToXml(b bool) string {
@@ -0,0 +1,640 @@
+package launchctlutil
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigurationBuilder_Build(t *testing.T) {
+	config, err := NewConfigurationBuilder().
+		SetKind(UserAgent).
+		SetLabel("com.testing").
+		SetRunAtLoad(true).
+		SetCommand("echo").
+		AddArgument("Hello world!").
+		SetLogParentPath("/tmp").
+		Build()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	exp := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+    <dict>
+        <key>Label</key>
+        <string>com.testing</string>
+        <key>ProgramArguments</key>
+        <array>
+            <string>echo</string>
+            <string>Hello world!</string>
+        </array>
+        <key>StandardOutPath</key>
+        <string>/tmp/com.testing.log</string>
+        <key>StandardErrorPath</key>
+        <string>/tmp/com.testing.log</string>
+        <key>RunAtLoad</key>
+        <true/>
+    </dict>
+</plist>
+`
+
+	if config.GetContents() != exp {
+		t.Fatalf("unexpected contents - got:\n%s", config.GetContents())
+	}
+}
+
+func TestConfigurationBuilder_WatchPaths(t *testing.T) {
+	config, err := NewConfigurationBuilder().
+		SetLabel("com.testing").
+		AddWatchPath("/tmp/watched").
+		AddQueueDirectory("/tmp/spool").
+		SetStartOnMount(true).
+		Build()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	exp := `        <key>WatchPaths</key>
+        <array>
+            <string>/tmp/watched</string>
+        </array>
+        <key>QueueDirectories</key>
+        <array>
+            <string>/tmp/spool</string>
+        </array>
+        <key>StartOnMount</key>
+        <true/>
+`
+
+	if !strings.Contains(config.GetContents(), exp) {
+		t.Fatalf("contents should contain:\n%s\ngot:\n%s", exp, config.GetContents())
+	}
+}
+
+func TestConfigurationBuilder_WatchPathsRelative(t *testing.T) {
+	_, err := NewConfigurationBuilder().
+		SetLabel("com.testing").
+		AddWatchPath("tmp/watched").
+		Build()
+	if err == nil {
+		t.Fatal("expected an error for a relative watch path")
+	}
+
+	_, err = NewConfigurationBuilder().
+		SetLabel("com.testing").
+		AddQueueDirectory("spool").
+		Build()
+	if err == nil {
+		t.Fatal("expected an error for a relative queue directory")
+	}
+}
+
+func TestConfigurationBuilder_Sockets(t *testing.T) {
+	config, err := NewConfigurationBuilder().
+		SetLabel("com.testing").
+		AddSocket("Listener", Socket{
+			Type:        SocketStream,
+			NodeName:    "localhost",
+			ServiceName: "8080",
+			Family:      SocketIPv4,
+			Bonjour:     true,
+		}).
+		AddSocket("Unix", Socket{
+			PathName: "/var/run/a.sock",
+			PathMode: 0600,
+		}).
+		AddSocket("Unix", Socket{
+			PathName:     "/var/run/b.sock",
+			BonjourNames: []string{"b"},
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	exp := `        <key>Sockets</key>
+        <dict>
+            <key>Listener</key>
+            <dict>
+                <key>SockType</key>
+                <string>stream</string>
+                <key>SockNodeName</key>
+                <string>localhost</string>
+                <key>SockServiceName</key>
+                <string>8080</string>
+                <key>SockFamily</key>
+                <string>IPv4</string>
+                <key>Bonjour</key>
+                <true/>
+            </dict>
+            <key>Unix</key>
+            <array>
+                <dict>
+                    <key>SockPathName</key>
+                    <string>/var/run/a.sock</string>
+                    <key>SockPathMode</key>
+                    <integer>384</integer>
+                </dict>
+                <dict>
+                    <key>SockPathName</key>
+                    <string>/var/run/b.sock</string>
+                    <key>Bonjour</key>
+                    <array>
+                        <string>b</string>
+                    </array>
+                </dict>
+            </array>
+        </dict>
+`
+
+	if !strings.Contains(config.GetContents(), exp) {
+		t.Fatalf("contents should contain:\n%s\ngot:\n%s", exp, config.GetContents())
+	}
+}
+
+func TestConfigurationBuilder_SocketsInvalid(t *testing.T) {
+	_, err := NewConfigurationBuilder().
+		SetLabel("com.testing").
+		AddSocket("Listener", Socket{
+			Family: SocketUnix,
+		}).
+		Build()
+	if err == nil {
+		t.Fatal("expected an error for a Unix socket without a path name")
+	}
+}
+
+func TestConfigurationBuilder_MachServicesAndLaunchEvents(t *testing.T) {
+	config, err := NewConfigurationBuilder().
+		SetLabel("com.testing").
+		AddMachService("com.testing.xpc", MachService{}).
+		AddMachService("com.testing.reset", MachService{ResetAtClose: true}).
+		AddLaunchEvent("com.apple.iokit.matching", "com.testing.attach", PlistDict{
+			{Key: "idVendor", Value: PlistInteger(1452)},
+			{Key: "IOProviderClass", Value: PlistString("IOUSBDevice")},
+			{Key: "IOMatchLaunchStream", Value: PlistBool(true)},
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	exp := `        <key>MachServices</key>
+        <dict>
+            <key>com.testing.xpc</key>
+            <true/>
+            <key>com.testing.reset</key>
+            <dict>
+                <key>ResetAtClose</key>
+                <true/>
+            </dict>
+        </dict>
+        <key>LaunchEvents</key>
+        <dict>
+            <key>com.apple.iokit.matching</key>
+            <dict>
+                <key>com.testing.attach</key>
+                <dict>
+                    <key>idVendor</key>
+                    <integer>1452</integer>
+                    <key>IOProviderClass</key>
+                    <string>IOUSBDevice</string>
+                    <key>IOMatchLaunchStream</key>
+                    <true/>
+                </dict>
+            </dict>
+        </dict>
+`
+
+	if !strings.Contains(config.GetContents(), exp) {
+		t.Fatalf("contents should contain:\n%s\ngot:\n%s", exp, config.GetContents())
+	}
+}
+
+func TestConfigurationBuilder_LaunchEventsInvalid(t *testing.T) {
+	_, err := NewConfigurationBuilder().
+		SetLabel("com.testing").
+		AddLaunchEvent("com.apple.notifyd.matching", "com.testing.event", PlistDict{
+			{Key: "Notification", Value: nil},
+		}).
+		Build()
+	if err == nil {
+		t.Fatal("expected an error for a nil descriptor value")
+	}
+
+	_, err = NewConfigurationBuilder().
+		SetLabel("com.testing").
+		AddLaunchEvent("com.apple.notifyd.matching", "com.testing.event", PlistDict{}).
+		AddLaunchEvent("com.apple.notifyd.matching", "com.testing.event", PlistDict{}).
+		Build()
+	if err == nil {
+		t.Fatal("expected an error for a duplicate event name")
+	}
+}
+
+func TestConfigurationBuilder_ProcessKeys(t *testing.T) {
+	config, err := NewConfigurationBuilder().
+		SetLabel("com.testing").
+		SetWorkingDirectory("/var/lib/testing").
+		SetNice(-5).
+		SetProcessType(BackgroundProcess).
+		SetLowPriorityIO(true).
+		SetAbandonProcessGroup(false).
+		Build()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	exp := `        <key>WorkingDirectory</key>
+        <string>/var/lib/testing</string>
+        <key>Nice</key>
+        <integer>-5</integer>
+        <key>ProcessType</key>
+        <string>Background</string>
+        <key>LowPriorityIO</key>
+        <true/>
+        <key>AbandonProcessGroup</key>
+        <false/>
+`
+
+	if !strings.Contains(config.GetContents(), exp) {
+		t.Fatalf("contents should contain:\n%s\ngot:\n%s", exp, config.GetContents())
+	}
+}
+
+func TestConfigurationBuilder_ProcessKeysInvalid(t *testing.T) {
+	_, err := NewConfigurationBuilder().
+		SetLabel("com.testing").
+		SetNice(21).
+		Build()
+	if err == nil {
+		t.Fatal("expected an error for an out of range nice value")
+	}
+
+	_, err = NewConfigurationBuilder().
+		SetLabel("com.testing").
+		SetProcessType("Turbo").
+		Build()
+	if err == nil {
+		t.Fatal("expected an error for an unknown process type")
+	}
+
+	_, err = NewConfigurationBuilder().
+		SetLabel("com.testing").
+		SetWorkingDirectory("relative").
+		Build()
+	if err == nil {
+		t.Fatal("expected an error for a relative working directory")
+	}
+}
+
+func TestConfigurationBuilder_TimingKeys(t *testing.T) {
+	config, err := NewConfigurationBuilder().
+		SetLabel("com.testing").
+		SetStartIntervalDuration(90 * time.Second).
+		SetThrottleInterval(1500 * time.Millisecond).
+		SetExitTimeOut(0).
+		SetTimeOut(time.Minute).
+		SetLaunchOnlyOnce(true).
+		Build()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	exp := `        <key>StartInterval</key>
+        <integer>90</integer>
+        <key>ThrottleInterval</key>
+        <integer>2</integer>
+        <key>ExitTimeOut</key>
+        <integer>0</integer>
+        <key>TimeOut</key>
+        <integer>60</integer>
+        <key>LaunchOnlyOnce</key>
+        <true/>
+`
+
+	if !strings.Contains(config.GetContents(), exp) {
+		t.Fatalf("contents should contain:\n%s\ngot:\n%s", exp, config.GetContents())
+	}
+}
+
+func TestConfigurationBuilder_TimingKeysSubSecond(t *testing.T) {
+	_, err := NewConfigurationBuilder().
+		SetLabel("com.testing").
+		SetThrottleInterval(500 * time.Millisecond).
+		Build()
+	if err == nil {
+		t.Fatal("expected an error for a sub-second throttle interval")
+	}
+
+	_, err = NewConfigurationBuilder().
+		SetLabel("com.testing").
+		SetStartIntervalDuration(time.Millisecond).
+		Build()
+	if err == nil {
+		t.Fatal("expected an error for a sub-second start interval")
+	}
+}
+
+func TestConfigurationBuilder_ResourceLimits(t *testing.T) {
+	config, err := NewConfigurationBuilder().
+		SetLabel("com.testing").
+		SetHardResourceLimit(ResourceNumberOfFiles, 65536).
+		SetSoftResourceLimit(ResourceNumberOfFiles, 8192).
+		SetSoftResourceLimit(ResourceCore, 0).
+		Build()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	exp := `        <key>SoftResourceLimits</key>
+        <dict>
+            <key>Core</key>
+            <integer>0</integer>
+            <key>NumberOfFiles</key>
+            <integer>8192</integer>
+        </dict>
+        <key>HardResourceLimits</key>
+        <dict>
+            <key>NumberOfFiles</key>
+            <integer>65536</integer>
+        </dict>
+`
+
+	if !strings.Contains(config.GetContents(), exp) {
+		t.Fatalf("contents should contain:\n%s\ngot:\n%s", exp, config.GetContents())
+	}
+}
+
+func TestConfigurationBuilder_ResourceLimitsSoftExceedsHard(t *testing.T) {
+	_, err := NewConfigurationBuilder().
+		SetLabel("com.testing").
+		SetSoftResourceLimit(ResourceNumberOfFiles, 10000).
+		SetHardResourceLimit(ResourceNumberOfFiles, 256).
+		Build()
+	if err == nil {
+		t.Fatal("expected an error when the soft limit exceeds the hard limit")
+	}
+}
+
+func TestConfigurationBuilder_ProgramAndSplitLogs(t *testing.T) {
+	config, err := NewConfigurationBuilder().
+		SetLabel("com.testing").
+		SetProgram("/usr/local/bin/testing").
+		SetCommand("testing-worker").
+		AddArgument("--verbose").
+		SetEnableGlobbing(true).
+		SetStandardInPath("/dev/null").
+		SetLogParentPath("/var/log").
+		SetSplitLogFiles(true).
+		Build()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	exp := `        <key>Program</key>
+        <string>/usr/local/bin/testing</string>
+        <key>ProgramArguments</key>
+        <array>
+            <string>testing-worker</string>
+            <string>--verbose</string>
+        </array>
+        <key>EnableGlobbing</key>
+        <true/>
+        <key>StandardInPath</key>
+        <string>/dev/null</string>
+        <key>StandardOutPath</key>
+        <string>/var/log/com.testing.out.log</string>
+        <key>StandardErrorPath</key>
+        <string>/var/log/com.testing.err.log</string>
+`
+
+	if !strings.Contains(config.GetContents(), exp) {
+		t.Fatalf("contents should contain:\n%s\ngot:\n%s", exp, config.GetContents())
+	}
+}
+
+func TestConfigurationBuilder_SessionAndHostScoping(t *testing.T) {
+	config, err := NewConfigurationBuilder().
+		SetLabel("com.testing").
+		SetDisabled(false).
+		AddLimitLoadToSessionType(AquaSession).
+		AddLimitLoadFromHost("build-server").
+		SetSessionCreate(true).
+		Build()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	exp := `        <key>Disabled</key>
+        <false/>
+        <key>LimitLoadToSessionType</key>
+        <string>Aqua</string>
+        <key>LimitLoadFromHosts</key>
+        <array>
+            <string>build-server</string>
+        </array>
+        <key>SessionCreate</key>
+        <true/>
+`
+
+	if !strings.Contains(config.GetContents(), exp) {
+		t.Fatalf("contents should contain:\n%s\ngot:\n%s", exp, config.GetContents())
+	}
+
+	config, err = NewConfigurationBuilder().
+		SetLabel("com.testing").
+		AddLimitLoadToSessionType(AquaSession).
+		AddLimitLoadToSessionType(LoginWindowSession).
+		Build()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	exp = `        <key>LimitLoadToSessionType</key>
+        <array>
+            <string>Aqua</string>
+            <string>LoginWindow</string>
+        </array>
+`
+
+	if !strings.Contains(config.GetContents(), exp) {
+		t.Fatalf("contents should contain:\n%s\ngot:\n%s", exp, config.GetContents())
+	}
+}
+
+func TestConfigurationBuilder_UnknownSessionType(t *testing.T) {
+	_, err := NewConfigurationBuilder().
+		SetLabel("com.testing").
+		AddLimitLoadToSessionType("SSH").
+		Build()
+	if err == nil {
+		t.Fatal("expected an error for an unknown session type")
+	}
+}
+
+func TestConfigurationBuilder_SetKey(t *testing.T) {
+	config, err := NewConfigurationBuilder().
+		SetLabel("com.testing").
+		SetKey("AssociatedBundleIdentifiers", PlistArray{PlistString("com.testing.app")}).
+		SetKey("MaterializeDatalessFiles", PlistBool(false)).
+		SetKey("Custom", PlistDict{
+			{Key: "Ratio", Value: PlistReal(0.25)},
+			{Key: "Created", Value: PlistDate(time.Date(2019, 8, 15, 10, 0, 0, 0, time.UTC))},
+			{Key: "Blob", Value: PlistData("hello")},
+			{Key: "Escaped", Value: PlistString("a < b && c")},
+		}).
+		SetKey("MaterializeDatalessFiles", PlistBool(true)).
+		Build()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	exp := `        <key>AssociatedBundleIdentifiers</key>
+        <array>
+            <string>com.testing.app</string>
+        </array>
+        <key>MaterializeDatalessFiles</key>
+        <true/>
+        <key>Custom</key>
+        <dict>
+            <key>Ratio</key>
+            <real>0.25</real>
+            <key>Created</key>
+            <date>2019-08-15T10:00:00Z</date>
+            <key>Blob</key>
+            <data>aGVsbG8=</data>
+            <key>Escaped</key>
+            <string>a &lt; b &amp;&amp; c</string>
+        </dict>
+`
+
+	if !strings.Contains(config.GetContents(), exp) {
+		t.Fatalf("contents should contain:\n%s\ngot:\n%s", exp, config.GetContents())
+	}
+
+	value, err := DecodePlist([]byte(config.GetContents()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	custom, _ := value.(PlistDict).Get("Custom")
+	escaped, _ := custom.(PlistDict).GetString("Escaped")
+	if escaped != "a < b && c" {
+		t.Fatalf("escaped string should round trip - got '%s'", escaped)
+	}
+}
+
+func TestConfigurationBuilder_SetKeyConflict(t *testing.T) {
+	_, err := NewConfigurationBuilder().
+		SetLabel("com.testing").
+		SetRunAtLoad(true).
+		SetKey("RunAtLoad", PlistBool(false)).
+		Build()
+	if err == nil {
+		t.Fatal("expected an error when SetKey conflicts with a typed setter")
+	}
+
+	_, err = NewConfigurationBuilder().
+		SetLabel("com.testing").
+		SetKey("Nothing", nil).
+		Build()
+	if err == nil {
+		t.Fatal("expected an error for a nil value")
+	}
+}
+
+func TestConfigurationBuilder_CalendarIntervalsAndKeepAlive(t *testing.T) {
+	config, err := NewConfigurationBuilder().
+		SetLabel("com.testing").
+		AddStartCalendarInterval(CalendarInterval{Hour: intPointer(3), Minute: intPointer(30)}).
+		AddStartCalendarInterval(CalendarInterval{Weekday: intPointer(0)}).
+		SetKeepAlive(true).
+		SetKeepAliveConditions(KeepAliveConditions{
+			SuccessfulExit: boolPointer(false),
+			PathState:      map[string]bool{"/tmp/b": false, "/tmp/a": true},
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	exp := `        <key>StartCalendarInterval</key>
+        <array>
+            <dict>
+                <key>Minute</key>
+                <integer>30</integer>
+                <key>Hour</key>
+                <integer>3</integer>
+            </dict>
+            <dict>
+                <key>Weekday</key>
+                <integer>0</integer>
+            </dict>
+        </array>
+`
+
+	if !strings.Contains(config.GetContents(), exp) {
+		t.Fatalf("contents should contain:\n%s\ngot:\n%s", exp, config.GetContents())
+	}
+
+	exp = `        <key>KeepAlive</key>
+        <dict>
+            <key>SuccessfulExit</key>
+            <false/>
+            <key>PathState</key>
+            <dict>
+                <key>/tmp/a</key>
+                <true/>
+                <key>/tmp/b</key>
+                <false/>
+            </dict>
+        </dict>
+`
+
+	if !strings.Contains(config.GetContents(), exp) {
+		t.Fatalf("contents should contain:\n%s\ngot:\n%s", exp, config.GetContents())
+	}
+}
+
+func TestConfigurationBuilder_CalendarIntervalOutOfRange(t *testing.T) {
+	_, err := NewConfigurationBuilder().
+		SetLabel("com.testing").
+		AddStartCalendarInterval(CalendarInterval{Hour: intPointer(24)}).
+		Build()
+	if err == nil {
+		t.Fatal("expected an error for an hour of 24")
+	}
+}
+
+func TestConfigurationBuilder_SetPlistFormat(t *testing.T) {
+	config, err := NewConfigurationBuilder().
+		SetLabel("com.testing").
+		SetCommand("echo").
+		SetRunAtLoad(true).
+		SetPlistFormat(PlistFormat{Canonical: true}).
+		Build()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	exp := `<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>com.testing</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>echo</string>
+	</array>
+	<key>RunAtLoad</key>
+	<true/>
+</dict>
+</plist>
+`
+
+	if !strings.HasSuffix(config.GetContents(), exp) {
+		t.Fatalf("contents should end with:\n%s\ngot:\n%s", exp, config.GetContents())
+	}
+}
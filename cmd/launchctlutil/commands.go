@@ -0,0 +1,494 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/stephen-fox/launchctlutil"
+)
+
+func validate(args []string) error {
+	flags := newFlagSet("validate", "<plist>...")
+	kind := &kindFlag{kind: launchctlutil.UserAgent}
+	flags.Var(kind, "kind", "The kind of service the files configure")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		return &usageError{message: "at least one configuration file must be specified"}
+	}
+
+	numInvalid := 0
+
+	for _, filePath := range flags.Args() {
+		_, err := readConfiguration(filePath, kind.kind)
+		if err != nil {
+			numInvalid++
+			fmt.Printf("%s: %s\n", filePath, err.Error())
+			continue
+		}
+
+		fmt.Printf("%s: ok\n", filePath)
+	}
+
+	if numInvalid > 0 {
+		return &exitCodeError{code: exitError}
+	}
+
+	return nil
+}
+
+func install(args []string) error {
+	flags := newFlagSet("install", "<plist|->")
+	kind := &kindFlag{kind: launchctlutil.UserAgent}
+	flags.Var(kind, "kind", "The kind of service to install")
+	username := flags.String("user", "", "Install the agent for the specified user. Requires root")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return &usageError{message: "exactly one configuration file must be specified"}
+	}
+
+	config, err := readConfiguration(flags.Arg(0), kind.kind)
+	if err != nil {
+		return err
+	}
+
+	if len(*username) > 0 {
+		err = launchctlutil.InstallForUser(config, *username)
+	} else {
+		err = launchctlutil.Install(config)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("installed %s\n", config.GetLabel())
+
+	return nil
+}
+
+func uninstall(args []string) error {
+	flags := newFlagSet("uninstall", "<label>")
+	kind := &kindFlag{kind: launchctlutil.UserAgent}
+	flags.Var(kind, "kind", "The kind of service to uninstall")
+	username := flags.String("user", "", "Uninstall the agent for the specified user. Requires root")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return &usageError{message: "exactly one label must be specified"}
+	}
+
+	var result launchctlutil.UninstallResult
+	if len(*username) > 0 {
+		result, err = launchctlutil.UninstallForUser(flags.Arg(0), kind.kind, *username)
+	} else {
+		result, err = launchctlutil.Uninstall(flags.Arg(0), kind.kind)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !result.Unloaded && !result.FileRemoved {
+		fmt.Printf("%s is not installed\n", flags.Arg(0))
+		return nil
+	}
+
+	if result.Unloaded {
+		fmt.Printf("unloaded %s\n", flags.Arg(0))
+	}
+
+	if result.FileRemoved {
+		fmt.Printf("removed %s\n", result.FilePath)
+	}
+
+	return nil
+}
+
+type statusOutput struct {
+	Label          string               `json:"label"`
+	Status         launchctlutil.Status `json:"status"`
+	Pid            *int                 `json:"pid,omitempty"`
+	LastExitStatus *int                 `json:"last_exit_status,omitempty"`
+}
+
+func status(args []string) error {
+	flags := newFlagSet("status", "<label>...")
+	kind := &kindFlag{kind: launchctlutil.UserAgent}
+	flags.Var(kind, "kind", "The kind of the services. Daemons require root")
+	asJson := flags.Bool("json", false, "Print the status as JSON")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		return &usageError{message: "at least one label must be specified"}
+	}
+
+	var statuses []statusOutput
+
+	for _, label := range flags.Args() {
+		details, err := launchctlutil.CurrentStatusForKind(label, kind.kind)
+		if err != nil {
+			if _, ok := err.(*launchctlutil.PrivilegeError); ok {
+				return err
+			}
+			return fmt.Errorf("failed to get status of '%s' - %s", label, err.Error())
+		}
+
+		s := statusOutput{
+			Label:  label,
+			Status: details.Status,
+		}
+
+		if details.Status == launchctlutil.Running && details.GotPid() {
+			pid := details.Pid
+			s.Pid = &pid
+		}
+
+		if details.Status != launchctlutil.NotInstalled && details.GotLastExitStatus() {
+			exit := details.LastExitStatus
+			s.LastExitStatus = &exit
+		}
+
+		statuses = append(statuses, s)
+	}
+
+	if *asJson {
+		return printJson(statuses)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "LABEL\tSTATUS\tPID\tLAST EXIT STATUS")
+	for _, s := range statuses {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Label, s.Status, optionalInt(s.Pid), optionalInt(s.LastExitStatus))
+	}
+
+	return w.Flush()
+}
+
+func diff(args []string) error {
+	flags := newFlagSet("diff", "<plist|->")
+	kind := &kindFlag{kind: launchctlutil.UserAgent}
+	flags.Var(kind, "kind", "The kind of service the file configures")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return &usageError{message: "exactly one configuration file must be specified"}
+	}
+
+	config, err := readConfiguration(flags.Arg(0), kind.kind)
+	if err != nil {
+		return err
+	}
+
+	desired, err := launchctlutil.ConfigurationPlist(config)
+	if err != nil {
+		return err
+	}
+
+	installedPath, err := config.GetFilePath()
+	if err != nil {
+		return err
+	}
+
+	var installed launchctlutil.PlistDict
+
+	_, statErr := os.Stat(installedPath)
+	if statErr == nil {
+		value, err := launchctlutil.ReadPlistFile(installedPath)
+		if err != nil {
+			return err
+		}
+
+		var ok bool
+		installed, ok = value.(launchctlutil.PlistDict)
+		if !ok {
+			return fmt.Errorf("%s does not contain a dictionary", installedPath)
+		}
+	} else {
+		fmt.Printf("%s is not installed\n", installedPath)
+	}
+
+	changes := launchctlutil.DiffPlistDicts(installed, desired)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	fmt.Printf("--- %s\n+++ %s\n", installedPath, flags.Arg(0))
+	printChanges(changes, "")
+
+	return &exitCodeError{code: exitError}
+}
+
+// printChanges prints each change as removed and added lines, starting
+// each line with the specified indentation.
+func printChanges(changes []launchctlutil.PlistChange, indent string) {
+	for _, change := range changes {
+		if change.Old != nil {
+			fmt.Printf("%s- %s = %s\n", indent, change.Key, formatValue(change.Old))
+		}
+
+		if change.New != nil {
+			fmt.Printf("%s+ %s = %s\n", indent, change.Key, formatValue(change.New))
+		}
+	}
+}
+
+func reconcile(args []string) error {
+	flags := newFlagSet("reconcile", "<plist>...")
+	kind := &kindFlag{kind: launchctlutil.UserAgent}
+	flags.Var(kind, "kind", "The kind of service the files configure")
+	prefix := flags.String("prefix", "", "The label prefix of the services to manage. Required")
+	var deleteKinds stringsFlag
+	flags.Var(&deleteKinds, "delete-kind", "Also delete services of this kind. May be specified more than once")
+	apply := flags.Bool("apply", false, "Apply the plan rather than only printing it")
+
+	filePaths, err := parseInterspersed(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if len(*prefix) == 0 {
+		return &usageError{message: "a label prefix must be specified"}
+	}
+
+	scope := launchctlutil.PlanScope{
+		LabelPrefix: *prefix,
+		Kinds:       []launchctlutil.Kind{kind.kind},
+	}
+
+	for _, name := range deleteKinds {
+		k, err := launchctlutil.ParseKind(name)
+		if err != nil {
+			return &usageError{message: err.Error()}
+		}
+
+		scope.Kinds = append(scope.Kinds, k)
+	}
+
+	var desired []launchctlutil.Configuration
+
+	for _, filePath := range filePaths {
+		config, err := launchctlutil.ReadConfiguration(filePath, kind.kind)
+		if err != nil {
+			return fmt.Errorf("failed to read '%s' - %s", filePath, err.Error())
+		}
+
+		desired = append(desired, config)
+	}
+
+	plan, err := launchctlutil.Plan(desired, scope)
+	if err != nil {
+		return err
+	}
+
+	if !plan.HasChanges() {
+		fmt.Println("no changes")
+		return nil
+	}
+
+	for _, action := range plan.Actions {
+		fmt.Printf("%s %s (%s)\n", action.Type, action.Label, action.FilePath)
+		printChanges(action.Changes, "  ")
+	}
+
+	if !*apply {
+		return nil
+	}
+
+	numFailed := 0
+
+	for _, result := range launchctlutil.Apply(plan) {
+		if result.Err != nil {
+			numFailed++
+			fmt.Printf("failed to %s %s - %s\n", result.Action.Type, result.Action.Label, result.Err.Error())
+		}
+	}
+
+	if numFailed > 0 {
+		return fmt.Errorf("%d of %d actions failed", numFailed, len(plan.Actions))
+	}
+
+	fmt.Printf("applied %d actions\n", len(plan.Actions))
+
+	return nil
+}
+
+type listOutput struct {
+	Label          string `json:"label"`
+	Kind           string `json:"kind"`
+	FilePath       string `json:"file_path"`
+	Program        string `json:"program,omitempty"`
+	Disabled       bool   `json:"disabled"`
+	Loaded         bool   `json:"loaded"`
+	Pid            int    `json:"pid,omitempty"`
+	LastExitStatus int    `json:"last_exit_status"`
+	Error          string `json:"error,omitempty"`
+}
+
+func list(args []string) error {
+	flags := newFlagSet("list", "")
+	var kinds stringsFlag
+	flags.Var(&kinds, "kind", "Only list services of this kind. May be specified more than once")
+	var bundlePaths stringsFlag
+	flags.Var(&bundlePaths, "bundle", "An application bundle to search for bundled services. May be specified more than once")
+	loadedOnly := flags.Bool("loaded", false, "Only list services that are loaded")
+	asJson := flags.Bool("json", false, "Print the services as JSON")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() > 0 {
+		return &usageError{message: "unexpected arguments: " + strings.Join(flags.Args(), " ")}
+	}
+
+	options := launchctlutil.DiscoverOptions{
+		BundlePaths: bundlePaths,
+	}
+
+	for _, name := range kinds {
+		kind, err := launchctlutil.ParseKind(name)
+		if err != nil {
+			return &usageError{message: err.Error()}
+		}
+
+		options.Kinds = append(options.Kinds, kind)
+	}
+
+	services, err := launchctlutil.Discover(options)
+	if err != nil {
+		return err
+	}
+
+	var outputs []listOutput
+
+	for _, service := range services {
+		if *loadedOnly && !service.Loaded {
+			continue
+		}
+
+		output := listOutput{
+			Label:          service.Label,
+			Kind:           service.Kind.String(),
+			FilePath:       service.FilePath,
+			Program:        service.Program,
+			Disabled:       service.Disabled,
+			Loaded:         service.Loaded,
+			Pid:            service.Pid,
+			LastExitStatus: service.LastExitStatus,
+		}
+
+		if service.ParseErr != nil {
+			output.Error = service.ParseErr.Error()
+		}
+
+		outputs = append(outputs, output)
+	}
+
+	if *asJson {
+		return printJson(outputs)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "LABEL\tKIND\tLOADED\tPID\tFILE")
+	for _, output := range outputs {
+		pid := "-"
+		if output.Pid > 0 {
+			pid = fmt.Sprint(output.Pid)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%t\t%s\t%s\n", output.Label, output.Kind, output.Loaded, pid, output.FilePath)
+	}
+
+	return w.Flush()
+}
+
+func printJson(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(v)
+}
+
+func optionalInt(i *int) string {
+	if i == nil {
+		return "-"
+	}
+
+	return fmt.Sprint(*i)
+}
+
+// formatValue returns a compact, single line representation of
+// a property list value.
+func formatValue(value launchctlutil.PlistValue) string {
+	switch v := value.(type) {
+	case launchctlutil.PlistString:
+		return strconv.Quote(string(v))
+	case launchctlutil.PlistInteger:
+		return strconv.FormatInt(int64(v), 10)
+	case launchctlutil.PlistReal:
+		return strconv.FormatFloat(float64(v), 'g', -1, 64)
+	case launchctlutil.PlistBool:
+		return strconv.FormatBool(bool(v))
+	case launchctlutil.PlistDate:
+		return time.Time(v).UTC().Format(time.RFC3339)
+	case launchctlutil.PlistData:
+		return "<" + base64.StdEncoding.EncodeToString(v) + ">"
+	case launchctlutil.PlistArray:
+		elements := make([]string, len(v))
+		for i, element := range v {
+			elements[i] = formatValue(element)
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case launchctlutil.PlistDict:
+		entries := make([]string, len(v))
+		for i, entry := range v {
+			entries[i] = entry.Key + " = " + formatValue(entry.Value)
+		}
+		return "{" + strings.Join(entries, "; ") + "}"
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+func schema(args []string) error {
+	flags := newFlagSet("schema", "")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() != 0 {
+		return &usageError{message: "schema does not accept arguments"}
+	}
+
+	_, err = os.Stdout.Write(launchctlutil.ConfigurationJSONSchema())
+	return err
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "launchctlutil-test-")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	validPath := path.Join(dir, "valid.plist")
+	err = generate([]string{"-label", "com.testing", "-command", "/bin/true", "-o", validPath})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	err = validate([]string{validPath})
+	if err != nil {
+		t.Fatalf("a generated configuration should be valid - got %s", err.Error())
+	}
+
+	invalidPath := path.Join(dir, "invalid.plist")
+	ioutil.WriteFile(invalidPath, []byte("<plist><dict><key>Label</key></dict></plist>"), 0600)
+
+	err = validate([]string{validPath, invalidPath})
+	if e, ok := err.(*exitCodeError); !ok || e.code != exitError {
+		t.Fatalf("an invalid configuration should fail with exit status %d - got %v", exitError, err)
+	}
+
+	err = validate(nil)
+	if _, ok := err.(*usageError); !ok {
+		t.Fatalf("validate without files should be a usage error - got %v", err)
+	}
+}
+
+func TestStatusUsage(t *testing.T) {
+	err := status([]string{"-kind", "Daemon"})
+	if _, ok := err.(*usageError); !ok {
+		t.Fatalf("status without labels should be a usage error - got %v", err)
+	}
+
+	err = status([]string{"-kind", "NotAKind", "com.testing"})
+	if err == nil {
+		t.Fatal("status with an unknown kind should fail")
+	}
+}
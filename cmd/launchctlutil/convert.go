@@ -0,0 +1,418 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/stephen-fox/launchctlutil"
+)
+
+const (
+	plistFormat       = "plist"
+	jsonFormat        = "json"
+	yamlFormat        = "yaml"
+	systemdFormat     = "systemd"
+	crontabFormat     = "crontab"
+	supervisordFormat = "supervisord"
+)
+
+// convertOptions are the options used by the input and output formats.
+type convertOptions struct {
+	inputPath  string
+	outputPath string
+	label      string
+	kind       launchctlutil.Kind
+	timerPath  string
+	prefix     string
+	format     launchctlutil.PlistFormat
+}
+
+// importers convert an input file to one or more property lists.
+var importers = map[string]func(convertOptions) ([]launchctlutil.PlistValue, error){
+	plistFormat: func(options convertOptions) ([]launchctlutil.PlistValue, error) {
+		value, err := readPlist(options.inputPath)
+		if err != nil {
+			return nil, err
+		}
+
+		return []launchctlutil.PlistValue{value}, nil
+	},
+	jsonFormat: func(options convertOptions) ([]launchctlutil.PlistValue, error) {
+		return decodeInput(options.inputPath, launchctlutil.DecodePlistJSON)
+	},
+	yamlFormat: func(options convertOptions) ([]launchctlutil.PlistValue, error) {
+		return decodeInput(options.inputPath, launchctlutil.DecodePlistYAML)
+	},
+	systemdFormat:     importSystemd,
+	crontabFormat:     importCrontab,
+	supervisordFormat: importSupervisord,
+}
+
+// multipleServiceFormats are the input formats that may contain more
+// than one service.
+var multipleServiceFormats = map[string]bool{
+	crontabFormat:     true,
+	supervisordFormat: true,
+}
+
+// exporters write a property list in an output format.
+var exporters = map[string]func(launchctlutil.PlistValue, convertOptions) error{
+	plistFormat: func(value launchctlutil.PlistValue, options convertOptions) error {
+		return writeOutput(options.outputPath, launchctlutil.EncodePlistFormat(value, options.format))
+	},
+	jsonFormat: func(value launchctlutil.PlistValue, options convertOptions) error {
+		return encodeOutput(value, options.outputPath, launchctlutil.EncodePlistJSON)
+	},
+	yamlFormat: func(value launchctlutil.PlistValue, options convertOptions) error {
+		return encodeOutput(value, options.outputPath, launchctlutil.EncodePlistYAML)
+	},
+	systemdFormat: exportSystemd,
+}
+
+// exportExtensions are the file extensions used when several property
+// lists are written to a directory.
+var exportExtensions = map[string]string{
+	plistFormat:   ".plist",
+	jsonFormat:    ".json",
+	yamlFormat:    ".yaml",
+	systemdFormat: ".service",
+}
+
+// extensionFormats are the formats of files with a given extension. They
+// are used when a format is not specified.
+var extensionFormats = map[string]string{
+	".plist":   plistFormat,
+	".json":    jsonFormat,
+	".yaml":    yamlFormat,
+	".yml":     yamlFormat,
+	".service": systemdFormat,
+}
+
+func convert(args []string) error {
+	flags := newFlagSet("convert", "<file|->")
+	from := flags.String("from", "", "The format of the input file: plist, json, yaml, systemd, crontab, "+
+		"or supervisord. Defaults to the format of the file's extension, or plist")
+	to := flags.String("to", "", "The format to convert the file to: plist, json, yaml, or systemd. "+
+		"Defaults to the format of the output file's extension, or plist")
+	output := flags.String("o", "", "The file to write the result to. Defaults to stdout. "+
+		"A systemd .timer unit is written next to the .service file. When converting a crontab or "+
+		"supervisord file, this is the directory that each service's file is written to")
+	label := flags.String("label", "", "The label of the service. Defaults to a name derived from the input file")
+	kind := &kindFlag{kind: launchctlutil.UserAgent}
+	flags.Var(kind, "kind", "The kind of service the input file configures")
+	timerPath := flags.String("timer", "", "The systemd .timer unit that activates the service. "+
+		"Defaults to the .timer file next to the .service file")
+	prefix := flags.String("label-prefix", "", "The prefix of the labels generated for crontab entries "+
+		"and supervisord programs. Defaults to 'cron' or 'supervisord'")
+	formatFlags := addPlistFormatFlags(flags)
+
+	inputs, err := parseInterspersed(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if len(inputs) != 1 {
+		return &usageError{message: "exactly one input file must be specified"}
+	}
+
+	if len(*from) == 0 {
+		*from = formatOf(inputs[0])
+	}
+
+	if len(*to) == 0 {
+		*to = formatOf(*output)
+	}
+
+	importer, ok := importers[*from]
+	if !ok {
+		return &usageError{message: "unsupported input format '" + *from + "'"}
+	}
+
+	exporter, ok := exporters[*to]
+	if !ok {
+		return &usageError{message: "unsupported output format '" + *to + "'"}
+	}
+
+	options := convertOptions{
+		inputPath:  inputs[0],
+		outputPath: *output,
+		label:      *label,
+		kind:       kind.kind,
+		timerPath:  *timerPath,
+		prefix:     *prefix,
+		format:     formatFlags.format(),
+	}
+
+	values, err := importer(options)
+	if err != nil {
+		return err
+	}
+
+	if !multipleServiceFormats[*from] {
+		return exporter(values[0], options)
+	}
+
+	if len(options.outputPath) > 0 {
+		err = os.MkdirAll(options.outputPath, 0755)
+		if err != nil {
+			return fmt.Errorf("failed to create output directory - %s", err.Error())
+		}
+	}
+
+	for i, value := range values {
+		valueOptions := options
+		label, _ := value.(launchctlutil.PlistDict).GetString("Label")
+
+		if len(options.outputPath) > 0 {
+			valueOptions.outputPath = path.Join(options.outputPath, label+exportExtensions[*to])
+		} else {
+			if i > 0 {
+				fmt.Println()
+			}
+
+			if *to == plistFormat {
+				fmt.Printf("# %s.plist\n", label)
+			}
+		}
+
+		err := exporter(value, valueOptions)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func importSystemd(options convertOptions) ([]launchctlutil.PlistValue, error) {
+	importOptions := launchctlutil.SystemdImportOptions{
+		Label: options.label,
+		Kind:  options.kind,
+	}
+
+	if len(options.timerPath) > 0 {
+		timer, err := ioutil.ReadFile(options.timerPath)
+		if err != nil {
+			return nil, err
+		}
+
+		importOptions.Timer = timer
+	}
+
+	var config launchctlutil.Configuration
+	var warnings []launchctlutil.ImportWarning
+	var err error
+
+	if options.inputPath == stdinArg {
+		var service []byte
+		service, err = ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stdin - %s", err.Error())
+		}
+
+		config, warnings, err = launchctlutil.ImportSystemdUnit(service, importOptions)
+	} else {
+		config, warnings, err = launchctlutil.ReadSystemdUnitFile(options.inputPath, importOptions)
+	}
+
+	printWarnings(warnings)
+
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := launchctlutil.ConfigurationPlist(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return []launchctlutil.PlistValue{value}, nil
+}
+
+func importCrontab(options convertOptions) ([]launchctlutil.PlistValue, error) {
+	importOptions := launchctlutil.CrontabImportOptions{
+		LabelPrefix: options.prefix,
+		Kind:        options.kind,
+	}
+
+	var configs []launchctlutil.Configuration
+	var warnings []launchctlutil.ImportWarning
+	var err error
+
+	if options.inputPath == stdinArg {
+		var crontab []byte
+		crontab, err = ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stdin - %s", err.Error())
+		}
+
+		configs, warnings, err = launchctlutil.ImportCrontab(crontab, importOptions)
+	} else {
+		configs, warnings, err = launchctlutil.ReadCrontabFile(options.inputPath, importOptions)
+	}
+
+	printWarnings(warnings)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return configurationPlists(configs)
+}
+
+func importSupervisord(options convertOptions) ([]launchctlutil.PlistValue, error) {
+	importOptions := launchctlutil.SupervisordImportOptions{
+		LabelPrefix: options.prefix,
+		Kind:        options.kind,
+	}
+
+	var configs []launchctlutil.Configuration
+	var warnings []launchctlutil.ImportWarning
+	var err error
+
+	if options.inputPath == stdinArg {
+		var contents []byte
+		contents, err = ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stdin - %s", err.Error())
+		}
+
+		configs, warnings, err = launchctlutil.ImportSupervisord(contents, importOptions)
+	} else {
+		configs, warnings, err = launchctlutil.ReadSupervisordFile(options.inputPath, importOptions)
+	}
+
+	printWarnings(warnings)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return configurationPlists(configs)
+}
+
+// configurationPlists returns the property lists of several
+// Configurations.
+func configurationPlists(configs []launchctlutil.Configuration) ([]launchctlutil.PlistValue, error) {
+	var values []launchctlutil.PlistValue
+
+	for _, config := range configs {
+		value, err := launchctlutil.ConfigurationPlist(config)
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+func exportSystemd(value launchctlutil.PlistValue, options convertOptions) error {
+	properties, ok := value.(launchctlutil.PlistDict)
+	if !ok {
+		return fmt.Errorf("the input is a %s rather than a dict", value.PlistType())
+	}
+
+	config, err := launchctlutil.NewConfiguration(properties, options.kind)
+	if err != nil {
+		return err
+	}
+
+	units, err := launchctlutil.ExportSystemdUnits(config)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range units.UnmappedKeys {
+		fmt.Fprintf(os.Stderr, "warning: %s has no systemd equivalent and was not converted\n", key)
+	}
+
+	if len(options.outputPath) == 0 {
+		fmt.Printf("# %s.service\n%s", config.GetLabel(), units.Service)
+		if len(units.Timer) > 0 {
+			fmt.Printf("\n# %s.timer\n%s", config.GetLabel(), units.Timer)
+		}
+		return nil
+	}
+
+	err = writeOutput(options.outputPath, []byte(units.Service))
+	if err != nil {
+		return err
+	}
+
+	if len(units.Timer) > 0 {
+		timerPath := strings.TrimSuffix(options.outputPath, ".service") + ".timer"
+		return writeOutput(timerPath, []byte(units.Timer))
+	}
+
+	return nil
+}
+
+// formatOf returns the format of a file based on its extension.
+func formatOf(filePath string) string {
+	format, ok := extensionFormats[strings.ToLower(path.Ext(filePath))]
+	if !ok {
+		return plistFormat
+	}
+
+	return format
+}
+
+// decodeInput reads the input file and decodes it. The file is read from
+// stdin if its path is "-".
+func decodeInput(filePath string, decode func([]byte) (launchctlutil.PlistValue, error)) ([]launchctlutil.PlistValue, error) {
+	var data []byte
+	var err error
+
+	if filePath == stdinArg {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(filePath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input - %s", err.Error())
+	}
+
+	value, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return []launchctlutil.PlistValue{value}, nil
+}
+
+// encodeOutput encodes the value and writes it to the output file.
+func encodeOutput(value launchctlutil.PlistValue, filePath string, encode func(launchctlutil.PlistValue) ([]byte, error)) error {
+	data, err := encode(value)
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(filePath, data)
+}
+
+// printWarnings writes import warnings to stderr.
+func printWarnings(warnings []launchctlutil.ImportWarning) {
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
+}
+
+// readPlist reads a property list file. The file is read from stdin
+// if its path is "-".
+func readPlist(filePath string) (launchctlutil.PlistValue, error) {
+	if filePath != stdinArg {
+		return launchctlutil.ReadPlistFile(filePath)
+	}
+
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stdin - %s", err.Error())
+	}
+
+	return launchctlutil.DecodePlist(data)
+}
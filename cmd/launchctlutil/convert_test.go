@@ -0,0 +1,105 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stephen-fox/launchctlutil"
+)
+
+func TestFormatOf(t *testing.T) {
+	tests := map[string]string{
+		"job.json":            jsonFormat,
+		"job.YAML":            yamlFormat,
+		"job.yml":             yamlFormat,
+		"example.service":     systemdFormat,
+		"com.example.plist":   plistFormat,
+		"supervisord.conf":    plistFormat,
+		"":                    plistFormat,
+		"/dir.json/com.other": plistFormat,
+	}
+
+	for filePath, exp := range tests {
+		if formatOf(filePath) != exp {
+			t.Fatalf("format of '%s' should be %s - got %s", filePath, exp, formatOf(filePath))
+		}
+	}
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "launchctlutil-test-")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	jobYAML := "Label: com.example.job\nProgramArguments:\n  - /bin/echo\n  - hello\nRunAtLoad: true\n"
+	yamlPath := path.Join(dir, "job.yaml")
+	ioutil.WriteFile(yamlPath, []byte(jobYAML), 0600)
+
+	plistPath := path.Join(dir, "com.example.job.plist")
+	err = convert([]string{yamlPath, "-o", plistPath})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	config, err := launchctlutil.ReadConfiguration(plistPath, launchctlutil.UserAgent)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if config.GetLabel() != "com.example.job" {
+		t.Fatalf("unexpected label - got '%s'", config.GetLabel())
+	}
+
+	roundTripPath := path.Join(dir, "round-trip.yaml")
+	err = convert([]string{plistPath, "-o", roundTripPath})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	roundTrip, _ := ioutil.ReadFile(roundTripPath)
+	if string(roundTrip) != jobYAML {
+		t.Fatalf("YAML did not round trip - got:\n%s", roundTrip)
+	}
+
+	err = convert([]string{"-to", "toml", yamlPath})
+	if _, ok := err.(*usageError); !ok {
+		t.Fatalf("an unsupported format should be a usage error - got %v", err)
+	}
+}
+
+func TestConvertCrontab(t *testing.T) {
+	dir, err := ioutil.TempDir("", "launchctlutil-test-")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	crontabPath := path.Join(dir, "crontab")
+	ioutil.WriteFile(crontabPath, []byte("0 * * * * /usr/bin/true\n@reboot /usr/bin/false\n"), 0600)
+
+	outputDir := path.Join(dir, "agents")
+	err = convert([]string{"-from", "crontab", "-label-prefix", "com.example", "-o", outputDir, crontabPath})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	infos, err := ioutil.ReadDir(outputDir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(infos) != 2 {
+		t.Fatalf("expected a plist for each crontab entry - got %d files", len(infos))
+	}
+
+	for _, info := range infos {
+		_, err := launchctlutil.ReadConfiguration(path.Join(outputDir, info.Name()), launchctlutil.UserAgent)
+		if err != nil {
+			t.Fatalf("%s: %s", info.Name(), err.Error())
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/stephen-fox/launchctlutil"
+)
+
+func generate(args []string) error {
+	flags := newFlagSet("generate", "")
+
+	kind := &kindFlag{kind: launchctlutil.UserAgent}
+	flags.Var(kind, "kind", "The kind of service (e.g., UserAgent, Daemon, GlobalAgent)")
+	label := flags.String("label", "", "The service's label (required)")
+	command := flags.String("command", "", "The command to execute (required)")
+	program := flags.String("program", "", "The path of the executable to run, if it differs from the command")
+	var arguments stringsFlag
+	flags.Var(&arguments, "arg", "An argument for the command. May be specified more than once")
+	var environment stringsFlag
+	flags.Var(&environment, "env", "An environment variable formatted as NAME=VALUE. May be specified more than once")
+	runAtLoad := flags.Bool("run-at-load", false, "Start the service when it is loaded")
+	keepAlive := flags.Bool("keep-alive", false, "Restart the service whenever it exits")
+	startInterval := flags.Duration("start-interval", 0, "Start the service at this interval (e.g., 5m)")
+	throttleInterval := flags.Duration("throttle-interval", 0, "The minimum amount of time between starts of the service")
+	logDir := flags.String("log-dir", "", "The directory to save the service's output to")
+	splitLogs := flags.Bool("split-logs", false, "Save stdout and stderr to separate files in the log directory")
+	stdoutPath := flags.String("stdout", "", "The file to save stdout to")
+	stderrPath := flags.String("stderr", "", "The file to save stderr to")
+	stdinPath := flags.String("stdin", "", "The file to read stdin from")
+	workingDir := flags.String("working-dir", "", "The working directory of the service")
+	userName := flags.String("user", "", "The user to run the service as")
+	groupName := flags.String("group", "", "The group to run the service as")
+	var watchPaths stringsFlag
+	flags.Var(&watchPaths, "watch-path", "A path that starts the service when modified. May be specified more than once")
+	disabled := flags.Bool("disabled", false, "Mark the service as disabled")
+	output := flags.String("o", "", "The file to write the configuration to. Defaults to stdout")
+	formatFlags := addPlistFormatFlags(flags)
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() > 0 {
+		return &usageError{message: "unexpected arguments: " + strings.Join(flags.Args(), " ")}
+	}
+
+	if len(*label) == 0 || len(*command) == 0 {
+		return &usageError{message: "the -label and -command options are required"}
+	}
+
+	builder := launchctlutil.NewConfigurationBuilder().
+		SetKind(kind.kind).
+		SetLabel(*label).
+		SetCommand(*command).
+		SetPlistFormat(formatFlags.format())
+
+	if *runAtLoad {
+		builder.SetRunAtLoad(true)
+	}
+
+	if *disabled {
+		builder.SetDisabled(true)
+	}
+
+	for _, argument := range arguments {
+		builder.AddArgument(argument)
+	}
+
+	for _, variable := range environment {
+		parts := strings.SplitN(variable, "=", 2)
+		if len(parts) != 2 {
+			return &usageError{message: "environment variable '" + variable + "' is not formatted as NAME=VALUE"}
+		}
+
+		builder.AddEnvironmentVariable(parts[0], parts[1])
+	}
+
+	for _, watchPath := range watchPaths {
+		builder.AddWatchPath(watchPath)
+	}
+
+	if len(*program) > 0 {
+		builder.SetProgram(*program)
+	}
+
+	if *keepAlive {
+		builder.SetKeepAlive(true)
+	}
+
+	if *startInterval > 0 {
+		builder.SetStartIntervalDuration(*startInterval)
+	}
+
+	if *throttleInterval > 0 {
+		builder.SetThrottleInterval(*throttleInterval)
+	}
+
+	if len(*logDir) > 0 {
+		builder.SetLogParentPath(*logDir).SetSplitLogFiles(*splitLogs)
+	}
+
+	if len(*stdoutPath) > 0 {
+		builder.SetStandardOutPath(*stdoutPath)
+	}
+
+	if len(*stderrPath) > 0 {
+		builder.SetStandardErrorPath(*stderrPath)
+	}
+
+	if len(*stdinPath) > 0 {
+		builder.SetStandardInPath(*stdinPath)
+	}
+
+	if len(*workingDir) > 0 {
+		builder.SetWorkingDirectory(*workingDir)
+	}
+
+	if len(*userName) > 0 {
+		builder.SetUserName(*userName)
+	}
+
+	if len(*groupName) > 0 {
+		builder.SetGroupName(*groupName)
+	}
+
+	config, err := builder.Build()
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(*output, []byte(config.GetContents()))
+}
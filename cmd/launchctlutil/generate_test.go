@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stephen-fox/launchctlutil"
+)
+
+func TestGenerate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "launchctlutil-test-")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	outputPath := path.Join(dir, "com.testing.plist")
+	err = generate([]string{"-label", "com.testing", "-command", "echo", "-arg", "hello",
+		"-keep-alive", "-env", "MODE=test", "-start-interval", "5m", "-o", outputPath})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	config, err := launchctlutil.ReadConfiguration(outputPath, launchctlutil.UserAgent)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	dict, _ := launchctlutil.ConfigurationPlist(config)
+
+	args, _ := dict.GetStrings("ProgramArguments")
+	_, hasRunAtLoad := dict.Get("RunAtLoad")
+	keepAlive, _ := dict.GetBool("KeepAlive")
+	interval, _ := dict.GetInteger("StartInterval")
+	if strings.Join(args, " ") != "echo hello" || hasRunAtLoad || !keepAlive || interval != 300 {
+		t.Fatalf("unexpected configuration - got:\n%s", config.GetContents())
+	}
+
+	for _, args := range [][]string{
+		{"-label", "com.testing"},
+		{"-label", "com.testing", "-command", "echo", "-env", "MODE"},
+		{"-label", "com.testing", "-command", "echo", "extra"},
+	} {
+		err = generate(args)
+		if _, ok := err.(*usageError); !ok {
+			t.Fatalf("%q should be a usage error - got %v", args, err)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// Command launchctlutil manages launchd services using the launchctlutil
+// library.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/stephen-fox/launchctlutil"
+)
+
+const (
+	usage = `usage: launchctlutil <command> [options] [arguments]
+
+commands:
+  generate   print a configuration built from command line options
+  validate   check that configuration files are valid
+  install    install and load a configuration file
+  uninstall  unload a service and remove its configuration file
+  status     show the status of services
+  diff       compare a configuration file with the installed one
+  list       list the services installed on disk
+  reconcile  install, update, and delete services to match configuration files
+  convert    convert a configuration file to another format
+  schema     print the JSON Schema of JSON and YAML configurations
+
+Run 'launchctlutil <command> -h' for a command's options.
+`
+
+	stdinArg = "-"
+
+	exitError = 1
+	exitUsage = 2
+)
+
+type command func(args []string) error
+
+var commands = map[string]command{
+	"generate":  generate,
+	"validate":  validate,
+	"install":   install,
+	"uninstall": uninstall,
+	"status":    status,
+	"diff":      diff,
+	"list":      list,
+	"reconcile": reconcile,
+	"convert":   convert,
+	"schema":    schema,
+}
+
+// usageError is returned when a command is used incorrectly.
+type usageError struct {
+	message string
+}
+
+func (o *usageError) Error() string {
+	return o.message
+}
+
+// exitCodeError is returned when a command fails without an error
+// message, such as when diff finds differences.
+type exitCodeError struct {
+	code int
+}
+
+func (o *exitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", o.code)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		os.Stderr.WriteString(usage)
+		os.Exit(exitUsage)
+	}
+
+	name := os.Args[1]
+	if name == "-h" || name == "-help" || name == "help" {
+		os.Stdout.WriteString(usage)
+		return
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command '%s'\n\n%s", name, usage)
+		os.Exit(exitUsage)
+	}
+
+	err := cmd(os.Args[2:])
+	if err != nil {
+		switch e := err.(type) {
+		case *exitCodeError:
+			os.Exit(e.code)
+		case *usageError:
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, e.message)
+			os.Exit(exitUsage)
+		}
+
+		if err == flag.ErrHelp {
+			return
+		}
+
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, err.Error())
+		os.Exit(exitError)
+	}
+}
+
+func newFlagSet(name string, argsUsage string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: launchctlutil %s [options] %s\n\noptions:\n", name, argsUsage)
+		flags.PrintDefaults()
+	}
+
+	return flags
+}
+
+// parseInterspersed parses flags that appear before or after the
+// positional arguments (e.g., "convert job.yaml -o job.plist"). It
+// returns the positional arguments.
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+
+	for {
+		err := flags.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+
+		if args[0] == "--" {
+			return append(positional, args[1:]...), nil
+		}
+
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// stringsFlag is a flag that may be specified more than once.
+type stringsFlag []string
+
+func (o *stringsFlag) String() string {
+	return strings.Join(*o, ",")
+}
+
+func (o *stringsFlag) Set(value string) error {
+	*o = append(*o, value)
+	return nil
+}
+
+// kindFlag is a flag whose value is a launchctlutil.Kind.
+type kindFlag struct {
+	kind launchctlutil.Kind
+}
+
+func (o *kindFlag) String() string {
+	return o.kind.String()
+}
+
+func (o *kindFlag) Set(value string) error {
+	kind, err := launchctlutil.ParseKind(value)
+	if err != nil {
+		return err
+	}
+
+	o.kind = kind
+
+	return nil
+}
+
+// plistFormatFlags are the flags that configure how property lists are
+// encoded.
+type plistFormatFlags struct {
+	tabs      *bool
+	sortKeys  *bool
+	canonical *bool
+}
+
+func addPlistFormatFlags(flags *flag.FlagSet) *plistFormatFlags {
+	return &plistFormatFlags{
+		tabs:      flags.Bool("tabs", false, "Indent the property list with tabs rather than spaces"),
+		sortKeys:  flags.Bool("sort-keys", false, "Sort the property list's keys"),
+		canonical: flags.Bool("canonical", false, "Write the property list exactly like 'plutil -convert xml1'"),
+	}
+}
+
+func (o *plistFormatFlags) format() launchctlutil.PlistFormat {
+	format := launchctlutil.PlistFormat{
+		SortKeys:  *o.sortKeys,
+		Canonical: *o.canonical,
+	}
+
+	if *o.tabs {
+		format.Indent = "\t"
+	}
+
+	return format
+}
+
+// readConfiguration reads a configuration file. The file is read from
+// stdin if its path is "-".
+func readConfiguration(filePath string, kind launchctlutil.Kind) (launchctlutil.Configuration, error) {
+	if filePath != stdinArg {
+		return launchctlutil.ReadConfiguration(filePath, kind)
+	}
+
+	contents, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stdin - %s", err.Error())
+	}
+
+	return launchctlutil.ParseConfiguration(contents, kind)
+}
+
+// writeOutput writes data to the specified file, or to stdout if
+// the file path is empty.
+func writeOutput(filePath string, data []byte) error {
+	if len(filePath) == 0 {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+
+	return ioutil.WriteFile(filePath, data, 0644)
+}
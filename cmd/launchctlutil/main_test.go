@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stephen-fox/launchctlutil"
+)
+
+func TestParseInterspersed(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	output := flags.String("o", "", "")
+	verbose := flags.Bool("v", false, "")
+
+	args, err := parseInterspersed(flags, []string{"in.yaml", "-o", "out.plist", "other", "-v", "--", "-literal"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if *output != "out.plist" || !*verbose {
+		t.Fatalf("flags after positional arguments were not parsed - got '%s' and %t", *output, *verbose)
+	}
+
+	if strings.Join(args, " ") != "in.yaml other -literal" {
+		t.Fatalf("unexpected positional arguments - got %q", args)
+	}
+
+	flags.SetOutput(ioutil.Discard)
+	_, err = parseInterspersed(flags, []string{"in.yaml", "-unknown"})
+	if err == nil {
+		t.Fatal("unknown flags should be rejected")
+	}
+}
+
+func TestKindFlag(t *testing.T) {
+	kind := &kindFlag{kind: launchctlutil.UserAgent}
+
+	err := kind.Set("daemon")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if kind.kind != launchctlutil.Daemon || kind.String() != "Daemon" {
+		t.Fatalf("kind should be Daemon - got %s", kind.kind)
+	}
+
+	err = kind.Set("bogus")
+	if err == nil {
+		t.Fatal("unknown kinds should be rejected")
+	}
+}
+
+func TestPlistFormatFlags(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	formatFlags := addPlistFormatFlags(flags)
+
+	err := flags.Parse([]string{"-tabs", "-sort-keys"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	format := formatFlags.format()
+	if format.Indent != "\t" || !format.SortKeys || format.Canonical {
+		t.Fatalf("unexpected format - got %+v", format)
+	}
+}
@@ -2,19 +2,287 @@ package launchctlutil
 
 import (
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 )
 
 const (
+	// UserAgent is an agent installed in the current user's
+	// ~/Library/LaunchAgents directory.
 	UserAgent Kind = iota
-	Daemon    Kind = iota
+
+	// Daemon is a system-wide daemon installed in /Library/LaunchDaemons.
+	Daemon Kind = iota
+
+	// GlobalAgent is an agent installed in /Library/LaunchAgents. It is
+	// installed by root, but launchd loads it for each user when
+	// they log in.
+	GlobalAgent Kind = iota
+
+	// SystemAgent is an agent provided by the operating system in
+	// /System/Library/LaunchAgents. It is read-only and is only
+	// useful for discovery.
+	SystemAgent Kind = iota
+
+	// SystemDaemon is a daemon provided by the operating system in
+	// /System/Library/LaunchDaemons. It is read-only and is only
+	// useful for discovery.
+	SystemDaemon Kind = iota
+
+	// BundledAgent is an agent shipped inside of an application bundle
+	// in "<App>.app/Contents/Library/LaunchAgents".
+	BundledAgent Kind = iota
+
+	// BundledDaemon is a daemon shipped inside of an application bundle
+	// in "<App>.app/Contents/Library/LaunchDaemons".
+	BundledDaemon Kind = iota
 )
 
 // Kind is the launchd type (e.g., a user agent).
 type Kind int
 
+// String returns the name of the Kind (e.g., "UserAgent").
+func (k Kind) String() string {
+	switch k {
+	case UserAgent:
+		return "UserAgent"
+	case Daemon:
+		return "Daemon"
+	case GlobalAgent:
+		return "GlobalAgent"
+	case SystemAgent:
+		return "SystemAgent"
+	case SystemDaemon:
+		return "SystemDaemon"
+	case BundledAgent:
+		return "BundledAgent"
+	case BundledDaemon:
+		return "BundledDaemon"
+	default:
+		return "Kind(" + strconv.Itoa(int(k)) + ")"
+	}
+}
+
+// isReadOnly returns true if services of this Kind are provided by the
+// operating system and cannot be installed or removed.
+func (k Kind) isReadOnly() bool {
+	return k == SystemAgent || k == SystemDaemon
+}
+
+// isSystemDomain returns true if services of this Kind are loaded into
+// launchd's system domain rather than a user's domain.
+func (k Kind) isSystemDomain() bool {
+	return k == Daemon || k == SystemDaemon || k == BundledDaemon
+}
+
+// requiresRootToInstall returns true if installing or removing
+// a service of this Kind requires root privileges.
+func (k Kind) requiresRootToInstall() bool {
+	return k.isSystemDomain() || k == GlobalAgent
+}
+
+// userAgentsDirectory returns the directory where a user's UserAgent
+// configurations are stored.
+func userAgentsDirectory(homePath string) string {
+	return strings.TrimSuffix(homePath, "/") + "/Library/LaunchAgents"
+}
+
+// directoryForKind returns the directory where configurations of the
+// specified Kind are stored. The bundle path is only used by the
+// BundledAgent and BundledDaemon kinds.
+func directoryForKind(kind Kind, bundlePath string) (string, error) {
+	switch kind {
+	case UserAgent:
+		homePath := os.Getenv("HOME")
+		if homePath == "" {
+			return "", errors.New("failed to determine HOME for UserAgent launchctl configuration")
+		}
+		return userAgentsDirectory(homePath), nil
+	case Daemon:
+		return "/Library/LaunchDaemons", nil
+	case GlobalAgent:
+		return "/Library/LaunchAgents", nil
+	case SystemAgent:
+		return "/System/Library/LaunchAgents", nil
+	case SystemDaemon:
+		return "/System/Library/LaunchDaemons", nil
+	case BundledAgent, BundledDaemon:
+		if bundlePath == "" {
+			return "", fmt.Errorf("an application bundle path is required for %s launchctl configurations", kind)
+		}
+		dirName := "LaunchAgents"
+		if kind == BundledDaemon {
+			dirName = "LaunchDaemons"
+		}
+		return strings.TrimSuffix(bundlePath, "/") + "/Contents/Library/" + dirName, nil
+	default:
+		return "", errors.New("an unknown launchctl configuration type was specified")
+	}
+}
+
+const (
+	// BackgroundProcess is for background jobs. The system applies
+	// resource limits to keep the job from disrupting the user.
+	BackgroundProcess ProcessType = "Background"
+
+	// StandardProcess is the default for jobs that are not
+	// background jobs.
+	StandardProcess ProcessType = "Standard"
+
+	// AdaptiveProcess is for jobs that move between the background
+	// and foreground based on XPC connection activity.
+	AdaptiveProcess ProcessType = "Adaptive"
+
+	// InteractiveProcess is for jobs that are critical to maintaining
+	// a responsive user experience. It should be used sparingly.
+	InteractiveProcess ProcessType = "Interactive"
+)
+
+// ProcessType is the type of a launchd service's process, which
+// determines the resource limits and scheduling policy that the
+// system applies to it.
+type ProcessType string
+
+const (
+	// AquaSession is a GUI login session.
+	AquaSession SessionType = "Aqua"
+
+	// BackgroundSession is a per-user session that is not associated
+	// with a login (e.g., for cron-like jobs).
+	BackgroundSession SessionType = "Background"
+
+	// LoginWindowSession is the session of the login window.
+	LoginWindowSession SessionType = "LoginWindow"
+
+	// StandardIOSession is a non-GUI login session, such as an
+	// SSH login.
+	StandardIOSession SessionType = "StandardIO"
+
+	// SystemSession is the system session that daemons run in.
+	SystemSession SessionType = "System"
+)
+
+// SessionType is a type of launchd session that a service can be
+// limited to loading in.
+type SessionType string
+
+// CalendarInterval is an entry of a launchd StartCalendarInterval.
+// A nil field is a wildcard that matches any value.
+type CalendarInterval struct {
+	Minute  *int
+	Hour    *int
+	Day     *int
+	Weekday *int
+	Month   *int
+}
+
+func (o CalendarInterval) validate() error {
+	fields := []struct {
+		name     string
+		value    *int
+		min, max int
+	}{
+		{name: "minute", value: o.Minute, min: 0, max: 59},
+		{name: "hour", value: o.Hour, min: 0, max: 23},
+		{name: "day", value: o.Day, min: 1, max: 31},
+		{name: "weekday", value: o.Weekday, min: 0, max: 7},
+		{name: "month", value: o.Month, min: 1, max: 12},
+	}
+
+	for _, f := range fields {
+		if f.value != nil && (*f.value < f.min || *f.value > f.max) {
+			return fmt.Errorf("calendar interval %s %d must be between %d and %d", f.name, *f.value, f.min, f.max)
+		}
+	}
+
+	return nil
+}
+
+func (o CalendarInterval) plistDict() PlistDict {
+	dict := PlistDict{}
+
+	add := func(key string, value *int) {
+		if value != nil {
+			dict = append(dict, PlistEntry{Key: key, Value: PlistInteger(*value)})
+		}
+	}
+
+	add("Minute", o.Minute)
+	add("Hour", o.Hour)
+	add("Day", o.Day)
+	add("Weekday", o.Weekday)
+	add("Month", o.Month)
+
+	return dict
+}
+
+// KeepAliveConditions are the conditions under which launchd keeps
+// a service running. A nil or empty field is not a condition.
+type KeepAliveConditions struct {
+	// SuccessfulExit keeps the service running until it exits
+	// with a non-zero status if true, or until it exits with
+	// a zero status if false.
+	SuccessfulExit *bool
+
+	// Crashed keeps the service running while it exits due to
+	// a signal if true, or while it exits normally if false.
+	Crashed *bool
+
+	// NetworkState keeps the service running while the network
+	// is up if true, or while it is down if false.
+	NetworkState *bool
+
+	// PathState maps paths to whether the service is kept running
+	// while they exist (true) or while they do not exist (false).
+	PathState map[string]bool
+
+	// OtherJobEnabled maps labels to whether the service is kept
+	// running while those services are loaded (true) or while they
+	// are not loaded (false).
+	OtherJobEnabled map[string]bool
+}
+
+func (o KeepAliveConditions) plistDict() PlistDict {
+	dict := PlistDict{}
+
+	addBool := func(key string, value *bool) {
+		if value != nil {
+			dict = append(dict, PlistEntry{Key: key, Value: PlistBool(*value)})
+		}
+	}
+
+	addMap := func(key string, values map[string]bool) {
+		if len(values) == 0 {
+			return
+		}
+
+		var names []string
+		for name := range values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		entries := PlistDict{}
+		for _, name := range names {
+			entries = append(entries, PlistEntry{Key: name, Value: PlistBool(values[name])})
+		}
+
+		dict = append(dict, PlistEntry{Key: key, Value: entries})
+	}
+
+	addBool("SuccessfulExit", o.SuccessfulExit)
+	addBool("Crashed", o.Crashed)
+	addBool("NetworkState", o.NetworkState)
+	addMap("PathState", o.PathState)
+	addMap("OtherJobEnabled", o.OtherJobEnabled)
+
+	return dict
+}
+
 // Configuration represents a launchd configuration.
 type Configuration interface {
 	// GetLabel returns the Configuration's label.
@@ -35,10 +303,23 @@ type Configuration interface {
 	IsInstalled() (bool, error)
 }
 
+// BundledConfiguration is a Configuration that may be contained in an
+// application bundle. The Configurations created by this package
+// implement it.
+type BundledConfiguration interface {
+	Configuration
+
+	// GetBundlePath returns the path to the application bundle that
+	// contains the Configuration. It is only set for the BundledAgent
+	// and BundledDaemon kinds.
+	GetBundlePath() string
+}
+
 type configuration struct {
-	label    string
-	contents string
-	kind     Kind
+	label      string
+	contents   string
+	kind       Kind
+	bundlePath string
 }
 
 func (c *configuration) GetLabel() string {
@@ -50,58 +331,253 @@ func (c *configuration) GetContents() string {
 }
 
 func (c *configuration) GetFilePath() (configFilePath string, err error) {
-	configFilePath = ""
-
-	switch c.kind {
-	case UserAgent:
-		homePath := os.Getenv("HOME")
-		if homePath == "" {
-			return "", errors.New("failed to determine HOME for UserAgent launchctl configuration")
-		}
-		configFilePath = homePath + "/Library/LaunchAgents"
-	case Daemon:
-		configFilePath = "/Library/LaunchDaemons"
-	default:
-		return "", errors.New("an unknown launchctl configuration type was specified")
+	dirPath, err := directoryForKind(c.kind, c.bundlePath)
+	if err != nil {
+		return "", err
 	}
 
-	return configFilePath + "/" + c.label + ".plist", nil
+	return dirPath + "/" + c.label + ".plist", nil
 }
 
 func (c *configuration) GetKind() Kind {
 	return c.kind
 }
 
+func (c *configuration) GetBundlePath() string {
+	return c.bundlePath
+}
+
 func (c *configuration) IsInstalled() (bool, error) {
-	if c.GetKind() == Daemon {
-		err := isRoot()
-		if err != nil {
-			return false, err
-		}
+	runner, err := checkPrivileges("list", c.GetKind())
+	if err != nil {
+		return false, err
+	}
+
+	// Global agents are not loaded into the installing user's
+	// domain, so only the file on disk can be checked.
+	if c.GetKind() == GlobalAgent {
+		return c.isFileInstalled(runner)
 	}
 
-	output, err := run("list")
+	output, err := run(runner, "list")
 	if err != nil {
 		return false, err
 	}
 
 	if strings.Contains(output, c.GetLabel()) {
-		configFilePath, err := c.GetFilePath()
-		if err != nil {
-			return false, err
+		return c.isFileInstalled(runner)
+	}
+
+	return false, nil
+}
+
+// isFileInstalled returns true if the Configuration's file exists
+// and matches its contents.
+func (c *configuration) isFileInstalled(runner Runner) (bool, error) {
+	configFilePath, err := c.GetFilePath()
+	if err != nil {
+		return false, err
+	}
+
+	_, err = os.Stat(configFilePath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	currentContents, err := runner.ReadFile(configFilePath)
+	if err != nil {
+		return false, err
+	}
+
+	return string(currentContents) == c.GetContents(), nil
+}
+
+// ParseKind returns the Kind with the specified name (e.g., "UserAgent").
+// The name is not case sensitive.
+func ParseKind(name string) (Kind, error) {
+	for kind := UserAgent; kind <= BundledDaemon; kind++ {
+		if strings.EqualFold(kind.String(), name) {
+			return kind, nil
 		}
-		_, temp := os.Stat(configFilePath)
-		if temp == nil {
-			currentContents, err := ioutil.ReadFile(configFilePath)
-			if err == nil {
-				if string(currentContents) == c.GetContents() {
-					return true, nil
-				}
-			} else {
-				return false, err
+	}
+
+	return 0, fmt.Errorf("unknown launchctl configuration kind '%s'", name)
+}
+
+// NewConfiguration creates a Configuration from the keys and values of
+// a launchd property list.
+func NewConfiguration(properties PlistDict, kind Kind) (Configuration, error) {
+	return NewConfigurationFormat(properties, kind, PlistFormat{})
+}
+
+// NewConfigurationFormat creates a Configuration from the keys and values
+// of a launchd property list, which is encoded in the specified format.
+func NewConfigurationFormat(properties PlistDict, kind Kind, format PlistFormat) (Configuration, error) {
+	err := validateConfigurationPlist(properties)
+	if err != nil {
+		return nil, err
+	}
+
+	label, _ := properties.GetString("Label")
+
+	return &configuration{
+		label:    label,
+		contents: encodePlistDocumentFormat(properties, format),
+		kind:     kind,
+	}, nil
+}
+
+// ParseConfiguration parses the contents of a launchd configuration file.
+// The Configuration's contents are the unmodified contents that were
+// provided.
+func ParseConfiguration(contents []byte, kind Kind) (Configuration, error) {
+	properties, err := decodeConfigurationPlist(contents)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateConfigurationPlist(properties)
+	if err != nil {
+		return nil, err
+	}
+
+	label, _ := properties.GetString("Label")
+
+	return &configuration{
+		label:    label,
+		contents: string(contents),
+		kind:     kind,
+	}, nil
+}
+
+// ReadConfiguration reads and parses a launchd configuration file.
+// Binary property lists are converted to XML using plutil.
+func ReadConfiguration(filePath string, kind Kind) (Configuration, error) {
+	value, err := ReadPlistFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	properties, ok := value.(PlistDict)
+	if !ok {
+		return nil, &plistTypeError{key: "root", expected: "dict", got: value.PlistType()}
+	}
+
+	contents, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(string(contents), binaryPlistPrefix) {
+		return NewConfiguration(properties, kind)
+	}
+
+	return ParseConfiguration(contents, kind)
+}
+
+// ConfigurationPlist returns the keys and values of a Configuration.
+func ConfigurationPlist(configuration Configuration) (PlistDict, error) {
+	return decodeConfigurationPlist([]byte(configuration.GetContents()))
+}
+
+func decodeConfigurationPlist(contents []byte) (PlistDict, error) {
+	value, err := DecodePlist(contents)
+	if err != nil {
+		return nil, err
+	}
+
+	properties, ok := value.(PlistDict)
+	if !ok {
+		return nil, &plistTypeError{key: "root", expected: "dict", got: value.PlistType()}
+	}
+
+	return properties, nil
+}
+
+// configurationKeyTypes maps launchd keys to the property list types
+// their values may have.
+var configurationKeyTypes = map[string][]string{
+	"Label":                   {"string"},
+	"Disabled":                {"bool"},
+	"UserName":                {"string"},
+	"GroupName":               {"string"},
+	"InitGroups":              {"bool"},
+	"Umask":                   {"integer"},
+	"Program":                 {"string"},
+	"ProgramArguments":        {"array"},
+	"EnableGlobbing":          {"bool"},
+	"EnvironmentVariables":    {"dict"},
+	"WorkingDirectory":        {"string"},
+	"RootDirectory":           {"string"},
+	"StandardInPath":          {"string"},
+	"StandardOutPath":         {"string"},
+	"StandardErrorPath":       {"string"},
+	"RunAtLoad":               {"bool"},
+	"KeepAlive":               {"bool", "dict"},
+	"StartInterval":           {"integer"},
+	"StartCalendarInterval":   {"dict", "array"},
+	"WatchPaths":              {"array"},
+	"QueueDirectories":        {"array"},
+	"StartOnMount":            {"bool"},
+	"LimitLoadToSessionType":  {"string", "array"},
+	"LimitLoadToHosts":        {"array"},
+	"LimitLoadFromHosts":      {"array"},
+	"SessionCreate":           {"bool"},
+	"Nice":                    {"integer"},
+	"ProcessType":             {"string"},
+	"LowPriorityIO":           {"bool"},
+	"LowPriorityBackgroundIO": {"bool"},
+	"AbandonProcessGroup":     {"bool"},
+	"EnablePressuredExit":     {"bool"},
+	"ThrottleInterval":        {"integer"},
+	"ExitTimeOut":             {"integer"},
+	"TimeOut":                 {"integer"},
+	"LaunchOnlyOnce":          {"bool"},
+	"SoftResourceLimits":      {"dict"},
+	"HardResourceLimits":      {"dict"},
+	"Sockets":                 {"dict"},
+	"MachServices":            {"dict"},
+	"LaunchEvents":            {"dict"},
+}
+
+// validateConfigurationPlist returns a non-nil error if the property list
+// is missing keys that launchd requires, or if a known key has a value
+// of the wrong type.
+func validateConfigurationPlist(properties PlistDict) error {
+	err := validatePlistKeys("root", properties)
+	if err != nil {
+		return err
+	}
+
+	label, _ := properties.GetString("Label")
+	if len(label) == 0 {
+		return errors.New("the Label key is required")
+	}
+
+	_, hasProgram := properties.Get("Program")
+	_, hasProgramArguments := properties.Get("ProgramArguments")
+	if !hasProgram && !hasProgramArguments {
+		return errors.New("either the Program or ProgramArguments key is required")
+	}
+
+	for _, entry := range properties {
+		types, isKnown := configurationKeyTypes[entry.Key]
+		if !isKnown {
+			continue
+		}
+
+		isValid := false
+		for _, t := range types {
+			if entry.Value.PlistType() == t {
+				isValid = true
+				break
 			}
 		}
+
+		if !isValid {
+			return &plistTypeError{key: entry.Key, expected: strings.Join(types, " or "), got: entry.Value.PlistType()}
+		}
 	}
 
-	return false, nil
+	return nil
 }
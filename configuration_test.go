@@ -0,0 +1,145 @@
+package launchctlutil
+
+import (
+	"testing"
+)
+
+func TestConfiguration_GetFilePath(t *testing.T) {
+	config := &configuration{
+		label:      "com.testing",
+		kind:       BundledDaemon,
+		bundlePath: "/Applications/Example.app/",
+	}
+
+	filePath, err := config.GetFilePath()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	exp := "/Applications/Example.app/Contents/Library/LaunchDaemons/com.testing.plist"
+	if filePath != exp {
+		t.Fatalf("file path should be '%s' - got '%s'", exp, filePath)
+	}
+}
+
+func TestBundledConfiguration(t *testing.T) {
+	config, err := NewConfigurationBuilder().
+		SetKind(BundledAgent).
+		SetBundlePath("/Applications/Example.app").
+		SetLabel("com.testing").
+		SetCommand("/bin/echo").
+		Build()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	bundled, ok := config.(BundledConfiguration)
+	if !ok {
+		t.Fatal("the configuration should implement BundledConfiguration")
+	}
+
+	if bundled.GetBundlePath() != "/Applications/Example.app" {
+		t.Fatalf("unexpected bundle path - got '%s'", bundled.GetBundlePath())
+	}
+}
+
+func TestConfiguration_GetFilePathGlobalAgent(t *testing.T) {
+	config := &configuration{
+		label: "com.testing",
+		kind:  GlobalAgent,
+	}
+
+	filePath, err := config.GetFilePath()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	exp := "/Library/LaunchAgents/com.testing.plist"
+	if filePath != exp {
+		t.Fatalf("file path should be '%s' - got '%s'", exp, filePath)
+	}
+}
+
+func TestConfiguration_GetFilePathBundledNoBundlePath(t *testing.T) {
+	config := &configuration{
+		label: "com.testing",
+		kind:  BundledAgent,
+	}
+
+	_, err := config.GetFilePath()
+	if err == nil {
+		t.Fatal("expected an error when the bundle path is not set")
+	}
+}
+
+func TestInstallSystemKind(t *testing.T) {
+	err := Install(&configuration{
+		label: "com.testing",
+		kind:  SystemDaemon,
+	})
+	if err == nil {
+		t.Fatal("expected an error when installing a SystemDaemon")
+	}
+}
+
+func TestParseConfiguration(t *testing.T) {
+	contents := `<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>com.testing</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>echo</string>
+	</array>
+</dict>
+</plist>
+`
+
+	config, err := ParseConfiguration([]byte(contents), UserAgent)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if config.GetLabel() != "com.testing" {
+		t.Fatalf("unexpected label - got '%s'", config.GetLabel())
+	}
+
+	if config.GetContents() != contents {
+		t.Fatal("contents should not be modified")
+	}
+}
+
+func TestNewConfigurationInvalid(t *testing.T) {
+	_, err := NewConfiguration(PlistDict{
+		{Key: "Label", Value: PlistString("com.testing")},
+	}, UserAgent)
+	if err == nil {
+		t.Fatal("expected an error when Program and ProgramArguments are missing")
+	}
+
+	_, err = NewConfiguration(PlistDict{
+		{Key: "Label", Value: PlistString("com.testing")},
+		{Key: "Program", Value: PlistString("/bin/echo")},
+		{Key: "RunAtLoad", Value: PlistString("yes")},
+	}, UserAgent)
+	if err == nil {
+		t.Fatal("expected an error when RunAtLoad is not a bool")
+	}
+}
+
+func TestParseKind(t *testing.T) {
+	kind, err := ParseKind("globalagent")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if kind != GlobalAgent {
+		t.Fatalf("kind should be GlobalAgent - got %s", kind)
+	}
+
+	_, err = ParseKind("nope")
+	if err == nil {
+		t.Fatal("expected an error for an unknown kind")
+	}
+}
@@ -0,0 +1,420 @@
+package launchctlutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultCrontabLabelPrefix = "cron"
+	defaultCrontabShell       = "/bin/sh"
+	crontabSource             = "crontab"
+	crontabLabelHashLength    = 8
+	maxCrontabLabelSlugLength = 32
+
+	// crontabShellCharacters are the characters that require a command
+	// to be run by a shell rather than executed directly.
+	crontabShellCharacters = "|&;<>()$`\\\"'*?[]#~=%{}\n"
+)
+
+var (
+	crontabEnvironmentLine = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)\s*=\s*(.*)$`)
+	crontabLabelSlug       = regexp.MustCompile(`[^a-z0-9-]+`)
+)
+
+// CrontabImportOptions configures ImportCrontab.
+type CrontabImportOptions struct {
+	// LabelPrefix is prepended to each generated label. It defaults
+	// to "cron".
+	LabelPrefix string
+
+	// Kind is the kind of the imported Configurations. It should
+	// usually be UserAgent because a user crontab's jobs run as the
+	// user that owns it.
+	Kind Kind
+
+	// LogDirectory, if set, is the directory that each job's output
+	// is saved to. cron emails the output of jobs, while launchd
+	// discards it by default.
+	LogDirectory string
+}
+
+// ReadCrontabFile reads a crontab file and converts it to Configurations.
+// See ImportCrontab for details.
+func ReadCrontabFile(filePath string, options CrontabImportOptions) ([]Configuration, []ImportWarning, error) {
+	crontab, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ImportCrontab(crontab, options)
+}
+
+// ImportCrontab converts each entry of a user crontab to a Configuration.
+//
+// Entries may use the five field schedule format or the @reboot, @yearly,
+// @annually, @monthly, @weekly, @daily, @midnight, and @hourly shortcuts.
+// Environment lines (e.g., "PATH=/usr/bin:/bin") are applied to the
+// entries that follow them. Commands are split into ProgramArguments if
+// they do not contain shell syntax. Otherwise, they are run using
+// "/bin/sh -c", or the shell set by a SHELL environment line.
+//
+// Labels are generated from the LabelPrefix, the name of the command's
+// program, and a hash of the entry's schedule and command (e.g.,
+// "cron.backup.1a2b3c4d"). They do not change when entries are
+// reordered, or when environment lines change.
+func ImportCrontab(crontab []byte, options CrontabImportOptions) ([]Configuration, []ImportWarning, error) {
+	prefix := options.LabelPrefix
+	if len(prefix) == 0 {
+		prefix = defaultCrontabLabelPrefix
+	}
+
+	var configs []Configuration
+	var warnings []ImportWarning
+
+	warn := func(line int, setting string, format string, a ...interface{}) {
+		warnings = append(warnings, ImportWarning{
+			Source:  crontabSource,
+			Line:    line,
+			Setting: setting,
+			Message: fmt.Sprintf(format, a...),
+		})
+	}
+
+	environment := PlistDict{}
+	shell := defaultCrontabShell
+	labels := make(map[string]bool)
+
+	for i, l := range strings.Split(string(crontab), "\n") {
+		lineNumber := i + 1
+		l = strings.TrimSpace(l)
+
+		if len(l) == 0 || l[0] == '#' {
+			continue
+		}
+
+		match := crontabEnvironmentLine.FindStringSubmatch(l)
+		if match != nil {
+			name := match[1]
+			value := unquoteValue(match[2])
+
+			switch name {
+			case "SHELL":
+				shell = value
+			case "MAILTO", "MAILFROM":
+				if len(value) > 0 {
+					warn(lineNumber, name, "launchd does not email the output of jobs")
+				}
+				continue
+			case "CRON_TZ", "TZ":
+				warn(lineNumber, name, "launchd runs jobs in the system's time zone")
+			}
+
+			setPlistString(&environment, name, value)
+			continue
+		}
+
+		entry, err := parseCrontabEntry(l)
+		if err != nil {
+			warn(lineNumber, "", "entry was not converted - %s", err.Error())
+			continue
+		}
+
+		command, hasStdin := convertCrontabPercentSigns(entry.command)
+		if hasStdin {
+			warn(lineNumber, "", "entry was not converted - unescaped '%%' characters, which cron sends to "+
+				"the command's stdin, have no launchd equivalent")
+			continue
+		}
+
+		arguments := []string{shell, "-c", command}
+		if !strings.ContainsAny(command, crontabShellCharacters) {
+			arguments = strings.Fields(command)
+		}
+
+		label := crontabLabel(prefix, entry, labels)
+		labels[label] = true
+
+		builder := NewConfigurationBuilder().
+			SetKind(options.Kind).
+			SetLabel(label).
+			SetCommand(arguments[0])
+
+		for _, argument := range arguments[1:] {
+			builder.AddArgument(argument)
+		}
+
+		for _, variable := range environment {
+			builder.AddEnvironmentVariable(variable.Key, string(variable.Value.(PlistString)))
+		}
+
+		if len(options.LogDirectory) > 0 {
+			builder.SetLogParentPath(options.LogDirectory)
+		}
+
+		if entry.atReboot {
+			builder.SetRunAtLoad(true)
+			if !options.Kind.isSystemDomain() {
+				warn(lineNumber, "@reboot", "agents are started when the user logs in rather than at boot")
+			}
+		}
+
+		for _, interval := range entry.intervals {
+			builder.AddStartCalendarInterval(interval)
+		}
+
+		config, err := builder.Build()
+		if err != nil {
+			warn(lineNumber, "", "entry was not converted - %s", err.Error())
+			continue
+		}
+
+		configs = append(configs, config)
+	}
+
+	return configs, warnings, nil
+}
+
+// crontabEntry is a job in a crontab.
+type crontabEntry struct {
+	schedule  string
+	command   string
+	atReboot  bool
+	intervals []CalendarInterval
+}
+
+// crontabShortcuts maps schedule shortcuts to their five field
+// equivalents.
+var crontabShortcuts = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var crontabMonths = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var crontabWeekdays = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+func parseCrontabEntry(l string) (crontabEntry, error) {
+	var entry crontabEntry
+
+	numScheduleFields := 5
+	if l[0] == '@' {
+		numScheduleFields = 1
+	}
+
+	fields := strings.Fields(l)
+	if len(fields) <= numScheduleFields {
+		return entry, fmt.Errorf("expected a schedule and a command - got '%s'", l)
+	}
+
+	// Preserve the command's original spacing.
+	command := l
+	for i := 0; i < numScheduleFields; i++ {
+		command = strings.TrimLeft(command, " \t")
+		command = command[len(fields[i]):]
+	}
+
+	entry.schedule = strings.Join(fields[:numScheduleFields], " ")
+	entry.command = strings.TrimSpace(command)
+
+	schedule := entry.schedule
+	if numScheduleFields == 1 {
+		if schedule == "@reboot" {
+			entry.atReboot = true
+			return entry, nil
+		}
+
+		var ok bool
+		schedule, ok = crontabShortcuts[schedule]
+		if !ok {
+			return entry, fmt.Errorf("unknown schedule '%s'", entry.schedule)
+		}
+	}
+
+	scheduleFields := strings.Fields(schedule)
+
+	minutes, err := parseCrontabField(scheduleFields[0], 0, 59, nil)
+	if err != nil {
+		return entry, err
+	}
+
+	hours, err := parseCrontabField(scheduleFields[1], 0, 23, nil)
+	if err != nil {
+		return entry, err
+	}
+
+	days, err := parseCrontabField(scheduleFields[2], 1, 31, nil)
+	if err != nil {
+		return entry, err
+	}
+
+	months, err := parseCrontabField(scheduleFields[3], 1, 12, crontabMonths)
+	if err != nil {
+		return entry, err
+	}
+
+	weekdays, err := parseCrontabField(scheduleFields[4], 0, 7, crontabWeekdays)
+	if err != nil {
+		return entry, err
+	}
+
+	// Sunday is both 0 and 7.
+	if len(weekdays) > 0 && weekdays[len(weekdays)-1] == 7 {
+		weekdays = weekdays[:len(weekdays)-1]
+		if len(weekdays) == 0 || weekdays[0] != 0 {
+			weekdays = append([]int{0}, weekdays...)
+		}
+
+		if len(weekdays) == 7 {
+			weekdays = nil
+		}
+	}
+
+	entry.intervals, err = expandCalendarIntervals(months, days, weekdays, hours, minutes)
+	if err != nil {
+		return entry, err
+	}
+
+	return entry, nil
+}
+
+// parseCrontabField parses a schedule field such as "*", "5", "1,15",
+// "9-17", "*/15", or "mon-fri". A nil slice is returned if every value
+// between min and max is included.
+func parseCrontabField(field string, min int, max int, names map[string]int) ([]int, error) {
+	included := make(map[int]bool)
+
+	parseValue := func(s string) (int, error) {
+		value, ok := names[strings.ToLower(s)]
+		if ok {
+			return value, nil
+		}
+
+		value, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid value '%s' in '%s'", s, field)
+		}
+
+		return value, nil
+	}
+
+	for _, item := range strings.Split(field, ",") {
+		step := 1
+		parts := strings.SplitN(item, "/", 2)
+		if len(parts) == 2 {
+			var err error
+			step, err = strconv.Atoi(parts[1])
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step in '%s'", item)
+			}
+		}
+
+		first := min
+		last := max
+
+		if parts[0] != "*" {
+			bounds := strings.SplitN(parts[0], "-", 2)
+
+			var err error
+			first, err = parseValue(bounds[0])
+			if err != nil {
+				return nil, err
+			}
+
+			switch {
+			case len(bounds) == 2:
+				last, err = parseValue(bounds[1])
+				if err != nil {
+					return nil, err
+				}
+			case len(parts) == 1:
+				last = first
+			}
+		}
+
+		if first < min || last > max || last < first {
+			return nil, fmt.Errorf("'%s' is not between %d and %d", item, min, max)
+		}
+
+		for value := first; value <= last; value += step {
+			included[value] = true
+		}
+	}
+
+	return sortedCalendarValues(included, max-min+1), nil
+}
+
+// convertCrontabPercentSigns replaces escaped percent signs in a command
+// with percent signs. It returns true if the command contains an unescaped
+// percent sign, which cron treats as the start of the command's stdin.
+func convertCrontabPercentSigns(command string) (string, bool) {
+	var converted strings.Builder
+
+	for i := 0; i < len(command); i++ {
+		switch {
+		case command[i] == '\\' && i+1 < len(command) && command[i+1] == '%':
+			converted.WriteByte('%')
+			i++
+		case command[i] == '%':
+			return "", true
+		default:
+			converted.WriteByte(command[i])
+		}
+	}
+
+	return converted.String(), false
+}
+
+// crontabLabel returns a label for the entry that does not conflict with
+// any of the existing labels.
+func crontabLabel(prefix string, entry crontabEntry, existing map[string]bool) string {
+	hash := sha256.Sum256([]byte(entry.schedule + "\n" + entry.command))
+
+	slug := "job"
+	fields := strings.Fields(entry.command)
+	if len(fields) > 0 {
+		name := crontabLabelSlug.ReplaceAllString(strings.ToLower(path.Base(fields[0])), "-")
+		name = strings.Trim(name, "-")
+		if len(name) > maxCrontabLabelSlugLength {
+			name = name[:maxCrontabLabelSlugLength]
+		}
+		if len(name) > 0 {
+			slug = name
+		}
+	}
+
+	label := prefix + "." + slug + "." + hex.EncodeToString(hash[:])[:crontabLabelHashLength]
+
+	// Identical entries are numbered in the order they appear.
+	unique := label
+	for i := 2; existing[unique]; i++ {
+		unique = label + "-" + strconv.Itoa(i)
+	}
+
+	return unique
+}
+
+func unquoteValue(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+
+	return value
+}
@@ -0,0 +1,199 @@
+package launchctlutil
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const testCrontab = `# m h dom mon dow command
+SHELL=/bin/bash
+PATH="/usr/local/bin:/usr/bin:/bin"
+MAILTO=someone@example.com
+
+*/30 9-17 * * mon-fri /usr/local/bin/backup --quiet
+@daily   cd /tmp && ./cleanup.sh > /dev/null 2>&1
+@reboot /usr/local/bin/agent
+0 0 1 * * date +\%Y
+5 4 * * sun echo "hello" | mail -s test root%body
+`
+
+func TestImportCrontab(t *testing.T) {
+	configs, warnings, err := ImportCrontab([]byte(testCrontab), CrontabImportOptions{
+		Kind: UserAgent,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(configs) != 4 {
+		t.Fatalf("expected 4 configurations - got %d", len(configs))
+	}
+
+	var dicts []PlistDict
+	for _, config := range configs {
+		dict, err := ConfigurationPlist(config)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		dicts = append(dicts, dict)
+	}
+
+	label, _ := dicts[0].GetString("Label")
+	if !strings.HasPrefix(label, "cron.backup.") || len(label) != len("cron.backup.")+8 {
+		t.Fatalf("unexpected label - got '%s'", label)
+	}
+
+	args, _ := dicts[0].GetStrings("ProgramArguments")
+	if strings.Join(args, "|") != "/usr/local/bin/backup|--quiet" {
+		t.Fatalf("unexpected program arguments - got %q", args)
+	}
+
+	intervals, _ := dicts[0].Get("StartCalendarInterval")
+	// 2 minutes * 9 hours * 5 weekdays.
+	if len(intervals.(PlistArray)) != 90 {
+		t.Fatalf("expected 90 calendar intervals - got %d", len(intervals.(PlistArray)))
+	}
+
+	environment, _ := dicts[0].Get("EnvironmentVariables")
+	path, _ := environment.(PlistDict).GetString("PATH")
+	if path != "/usr/local/bin:/usr/bin:/bin" {
+		t.Fatalf("unexpected PATH - got '%s'", path)
+	}
+
+	args, _ = dicts[1].GetStrings("ProgramArguments")
+	if strings.Join(args, "|") != "/bin/bash|-c|cd /tmp && ./cleanup.sh > /dev/null 2>&1" {
+		t.Fatalf("shell command was not wrapped - got %q", args)
+	}
+
+	interval, _ := dicts[1].Get("StartCalendarInterval")
+	hour, _ := interval.(PlistDict).GetInteger("Hour")
+	minute, _ := interval.(PlistDict).GetInteger("Minute")
+	if hour != 0 || minute != 0 {
+		t.Fatalf("@daily should run at midnight - got %d:%d", hour, minute)
+	}
+
+	runAtLoad, _ := dicts[2].GetBool("RunAtLoad")
+	if !runAtLoad {
+		t.Fatal("@reboot entry should set RunAtLoad")
+	}
+
+	args, _ = dicts[3].GetStrings("ProgramArguments")
+	if args[len(args)-1] != "date +%Y" {
+		t.Fatalf("escaped percent sign was not converted - got %q", args)
+	}
+
+	var settings []string
+	for _, warning := range warnings {
+		settings = append(settings, warning.Setting)
+	}
+
+	joined := strings.Join(settings, ",")
+	if joined != "MAILTO,@reboot," {
+		t.Fatalf("unexpected warnings - got %q", warnings)
+	}
+
+	if warnings[2].Line != 10 {
+		t.Fatalf("stdin warning should be for line 10 - got %d", warnings[2].Line)
+	}
+}
+
+func TestImportCrontabLabels(t *testing.T) {
+	crontab := "0 * * * * /usr/bin/true\n0 * * * * /usr/bin/true\n"
+
+	configs, _, err := ImportCrontab([]byte(crontab), CrontabImportOptions{LabelPrefix: "com.example"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	first := configs[0].GetLabel()
+	if configs[1].GetLabel() != first+"-2" {
+		t.Fatalf("duplicate entries should get numbered labels - got '%s' and '%s'",
+			first, configs[1].GetLabel())
+	}
+
+	reordered, _, err := ImportCrontab([]byte("FOO=bar\n"+crontab), CrontabImportOptions{LabelPrefix: "com.example"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if reordered[0].GetLabel() != first {
+		t.Fatalf("labels should not depend on environment lines - got '%s' and '%s'",
+			first, reordered[0].GetLabel())
+	}
+}
+
+func TestImportCrontabSunday(t *testing.T) {
+	tests := map[string][]int{
+		"* * * * 7":     {0},
+		"* * * * sun":   {0},
+		"* * * * 0,7":   {0},
+		"* * * * 5-7":   {0, 5, 6},
+		"* * * * 0-7":   nil,
+		"* * * * 1-7/1": nil,
+	}
+
+	for schedule, exp := range tests {
+		configs, _, err := ImportCrontab([]byte(schedule+" /usr/bin/true\n"), CrontabImportOptions{})
+		if err != nil {
+			t.Fatalf("%s: %s", schedule, err.Error())
+		}
+
+		dict, _ := ConfigurationPlist(configs[0])
+		calendar, _ := dict.Get("StartCalendarInterval")
+		intervals, err := calendarIntervalsFromPlist(calendar)
+		if err != nil {
+			t.Fatalf("%s: %s", schedule, err.Error())
+		}
+
+		var weekdays []int
+		for _, interval := range intervals {
+			if interval.Weekday != nil {
+				weekdays = append(weekdays, *interval.Weekday)
+			}
+		}
+
+		if fmt.Sprint(weekdays) != fmt.Sprint(exp) {
+			t.Fatalf("%s: expected weekdays %v - got %v", schedule, exp, weekdays)
+		}
+	}
+}
+
+func TestParseCrontabField(t *testing.T) {
+	tests := map[string][]int{
+		"*":       nil,
+		"*/15":    {0, 15, 30, 45},
+		"1,5-7":   {1, 5, 6, 7},
+		"10-20/5": {10, 15, 20},
+	}
+
+	for field, exp := range tests {
+		values, err := parseCrontabField(field, 0, 59, nil)
+		if err != nil {
+			t.Fatalf("failed to parse '%s' - %s", field, err.Error())
+		}
+
+		if len(values) != len(exp) {
+			t.Fatalf("'%s' should be %v - got %v", field, exp, values)
+		}
+
+		for i := range exp {
+			if values[i] != exp[i] {
+				t.Fatalf("'%s' should be %v - got %v", field, exp, values)
+			}
+		}
+	}
+
+	weekdays, err := parseCrontabField("MON-fri", 0, 7, crontabWeekdays)
+	if err != nil || len(weekdays) != 5 || weekdays[0] != 1 {
+		t.Fatalf("unexpected weekdays - got %v, %v", weekdays, err)
+	}
+
+	for _, field := range []string{"60", "5-1", "*/0", "foo"} {
+		_, err := parseCrontabField(field, 0, 59, nil)
+		if err == nil {
+			t.Fatalf("'%s' should be rejected", field)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package launchctlutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	plistFileSuffix = ".plist"
+)
+
+// DiscoverOptions configures Discover.
+type DiscoverOptions struct {
+	// Kinds are the kinds of services to discover. UserAgent,
+	// GlobalAgent, Daemon, SystemAgent, and SystemDaemon services
+	// are discovered if no kinds are specified.
+	Kinds []Kind
+
+	// BundlePaths are application bundles to search for BundledAgent
+	// and BundledDaemon services.
+	BundlePaths []string
+
+	// SkipLoadedCheck skips correlating the discovered services
+	// with the output of "launchctl list".
+	SkipLoadedCheck bool
+}
+
+// kinds returns the kinds of services to discover.
+func (o DiscoverOptions) kinds() []Kind {
+	if len(o.Kinds) == 0 {
+		return []Kind{UserAgent, GlobalAgent, Daemon, SystemAgent, SystemDaemon}
+	}
+
+	return o.Kinds
+}
+
+// DiscoveredService is a launchd configuration file found on disk.
+type DiscoveredService struct {
+	Label            string
+	Kind             Kind
+	BundlePath       string
+	FilePath         string
+	Program          string
+	ProgramArguments []string
+	Schedule         Schedule
+	Disabled         bool
+
+	// Loaded is true if the service's label appears in the output
+	// of "launchctl list". Daemons are only reported as loaded
+	// when running as root.
+	Loaded         bool
+	Pid            int
+	LastExitStatus int
+
+	// ParseErr is non-nil if the configuration file could not
+	// be parsed.
+	ParseErr error
+}
+
+// Schedule describes when launchd starts a service.
+type Schedule struct {
+	RunAtLoad              bool
+	KeepAlive              bool
+	StartInterval          int
+	StartCalendarIntervals []CalendarInterval
+}
+
+// Discover walks the launchd configuration directories and returns
+// the services found in them.
+func Discover(options DiscoverOptions) ([]DiscoveredService, error) {
+	var services []DiscoveredService
+
+	for _, kind := range options.kinds() {
+		bundlePaths := []string{""}
+		if kind == BundledAgent || kind == BundledDaemon {
+			bundlePaths = options.BundlePaths
+		}
+
+		for _, bundlePath := range bundlePaths {
+			dirPath, err := directoryForKind(kind, bundlePath)
+			if err != nil {
+				return nil, err
+			}
+
+			found, err := discoverDirectory(dirPath, kind, bundlePath)
+			if err != nil {
+				return nil, err
+			}
+
+			services = append(services, found...)
+		}
+	}
+
+	if options.SkipLoadedCheck {
+		return services, nil
+	}
+
+	loaded, err := List()
+	if err != nil {
+		return nil, err
+	}
+
+	markLoaded(services, loaded)
+
+	return services, nil
+}
+
+func discoverDirectory(dirPath string, kind Kind, bundlePath string) ([]DiscoveredService, error) {
+	infos, err := ioutil.ReadDir(dirPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var services []DiscoveredService
+
+	for _, info := range infos {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), plistFileSuffix) {
+			continue
+		}
+
+		service := DiscoveredService{
+			Kind:       kind,
+			BundlePath: bundlePath,
+			FilePath:   path.Join(dirPath, info.Name()),
+		}
+
+		value, err := ReadPlistFile(service.FilePath)
+		if err != nil {
+			service.ParseErr = err
+		} else {
+			service.ParseErr = service.populate(value)
+		}
+
+		services = append(services, service)
+	}
+
+	return services, nil
+}
+
+func (o *DiscoveredService) populate(value PlistValue) error {
+	dict, ok := value.(PlistDict)
+	if !ok {
+		return &plistTypeError{key: "root", expected: "dict", got: value.PlistType()}
+	}
+
+	o.Label, _ = dict.GetString("Label")
+	o.ProgramArguments, _ = dict.GetStrings("ProgramArguments")
+
+	o.Program, _ = dict.GetString("Program")
+	if len(o.Program) == 0 && len(o.ProgramArguments) > 0 {
+		o.Program = o.ProgramArguments[0]
+	}
+
+	o.Disabled, _ = dict.GetBool("Disabled")
+	o.Schedule.RunAtLoad, _ = dict.GetBool("RunAtLoad")
+
+	keepAlive, hasKeepAlive := dict.Get("KeepAlive")
+	if hasKeepAlive {
+		// A dictionary means the service is kept alive under
+		// certain conditions.
+		keepAliveBool, isBool := keepAlive.(PlistBool)
+		o.Schedule.KeepAlive = !isBool || bool(keepAliveBool)
+	}
+
+	interval, _ := dict.GetInteger("StartInterval")
+	o.Schedule.StartInterval = int(interval)
+
+	calendar, hasCalendar := dict.Get("StartCalendarInterval")
+	if hasCalendar {
+		intervals, err := calendarIntervalsFromPlist(calendar)
+		if err != nil {
+			return err
+		}
+		o.Schedule.StartCalendarIntervals = intervals
+	}
+
+	return nil
+}
+
+func markLoaded(services []DiscoveredService, loaded []ListEntry) {
+	labelsToEntries := make(map[string]ListEntry)
+	for _, entry := range loaded {
+		labelsToEntries[entry.Label] = entry
+	}
+
+	for i := range services {
+		entry, ok := labelsToEntries[services[i].Label]
+		if !ok || len(services[i].Label) == 0 {
+			continue
+		}
+
+		services[i].Loaded = true
+		services[i].Pid = entry.Pid
+		services[i].LastExitStatus = entry.LastExitStatus
+	}
+}
+
+func calendarIntervalsFromPlist(value PlistValue) ([]CalendarInterval, error) {
+	var dicts []PlistDict
+
+	switch v := value.(type) {
+	case PlistDict:
+		dicts = append(dicts, v)
+	case PlistArray:
+		for _, element := range v {
+			dict, ok := element.(PlistDict)
+			if !ok {
+				return nil, &plistTypeError{key: "StartCalendarInterval", expected: "dict", got: element.PlistType()}
+			}
+			dicts = append(dicts, dict)
+		}
+	default:
+		return nil, &plistTypeError{key: "StartCalendarInterval", expected: "dict or array", got: value.PlistType()}
+	}
+
+	var intervals []CalendarInterval
+
+	for _, dict := range dicts {
+		var interval CalendarInterval
+		for _, entry := range dict {
+			i, ok := entry.Value.(PlistInteger)
+			if !ok {
+				return nil, &plistTypeError{key: entry.Key, expected: "integer", got: entry.Value.PlistType()}
+			}
+			v := int(i)
+
+			switch entry.Key {
+			case "Minute":
+				interval.Minute = &v
+			case "Hour":
+				interval.Hour = &v
+			case "Day":
+				interval.Day = &v
+			case "Weekday":
+				interval.Weekday = &v
+			case "Month":
+				interval.Month = &v
+			}
+		}
+		intervals = append(intervals, interval)
+	}
+
+	return intervals, nil
+}
@@ -0,0 +1,87 @@
+package launchctlutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestDiscoverDirectory(t *testing.T) {
+	dirPath, err := ioutil.TempDir("", "launchctlutil-")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dirPath)
+
+	err = ioutil.WriteFile(path.Join(dirPath, "com.testing.plist"), []byte(`<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>com.testing</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>/usr/bin/true</string>
+	</array>
+	<key>StartCalendarInterval</key>
+	<dict>
+		<key>Minute</key>
+		<integer>10</integer>
+	</dict>
+</dict>
+</plist>
+`), 0600)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	err = ioutil.WriteFile(path.Join(dirPath, "com.broken.plist"), []byte("<plist>"), 0600)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	err = ioutil.WriteFile(path.Join(dirPath, "README"), []byte("ignored"), 0600)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	services, err := discoverDirectory(dirPath, UserAgent, "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(services) != 2 {
+		t.Fatalf("expected 2 services - got %d", len(services))
+	}
+
+	broken := services[0]
+	if broken.ParseErr == nil {
+		t.Fatal("expected a parse error for the broken plist")
+	}
+
+	service := services[1]
+	if service.ParseErr != nil {
+		t.Fatal(service.ParseErr.Error())
+	}
+
+	if service.Label != "com.testing" {
+		t.Fatalf("label should be 'com.testing' - got '%s'", service.Label)
+	}
+
+	if service.Program != "/usr/bin/true" {
+		t.Fatalf("program should be '/usr/bin/true' - got '%s'", service.Program)
+	}
+
+	if len(service.Schedule.StartCalendarIntervals) != 1 || *service.Schedule.StartCalendarIntervals[0].Minute != 10 {
+		t.Fatalf("unexpected calendar intervals - got %v", service.Schedule.StartCalendarIntervals)
+	}
+
+	markLoaded(services, []ListEntry{{Label: "com.testing", Pid: 42}})
+
+	if !services[1].Loaded || services[1].Pid != 42 {
+		t.Fatal("service should be marked as loaded with PID 42")
+	}
+
+	if services[0].Loaded {
+		t.Fatal("broken service should not be marked as loaded")
+	}
+}
@@ -0,0 +1,169 @@
+package launchctlutil
+
+import (
+	"os"
+	"path"
+	"strings"
+)
+
+var (
+	// synthesizedLabelPrefixes are the label prefixes of services that
+	// launchd creates without a configuration file.
+	synthesizedLabelPrefixes = []string{
+		"application.",
+		"com.apple.xpc.launchd.oneshot.",
+	}
+)
+
+// DriftReport describes the differences between the launchd configuration
+// files on disk and the services that are loaded.
+type DriftReport struct {
+	// NotLoaded are configuration files on disk whose services are not
+	// loaded. This includes services unloaded with RemoveService, which
+	// launchd loads again after rebooting or logging out. Services that
+	// are disabled by their configuration are not included.
+	NotLoaded []DiscoveredService
+
+	// NoConfigFile are loaded services that do not have a configuration
+	// file on disk (e.g., the file was deleted without unloading the
+	// service). It is only populated when every non-bundled kind in
+	// the current domain is discovered. Services that launchd creates
+	// for applications are not included. Note that services loaded by
+	// other means, such as XPC services shipped in application bundles
+	// that were not discovered, are reported as well.
+	NoConfigFile []ListEntry
+
+	// LabelMismatch are configuration files whose file name does not
+	// match the Label they contain.
+	LabelMismatch []DiscoveredService
+
+	// DuplicateLabels maps a label to each of the configuration files
+	// that contain it when it appears in more than one file.
+	DuplicateLabels map[string][]DiscoveredService
+}
+
+// HasDrift returns true if the report contains any differences.
+func (o DriftReport) HasDrift() bool {
+	return len(o.NotLoaded) > 0 ||
+		len(o.NoConfigFile) > 0 ||
+		len(o.LabelMismatch) > 0 ||
+		len(o.DuplicateLabels) > 0
+}
+
+// DetectDrift discovers the configuration files specified by options and
+// compares them to the output of "launchctl list".
+//
+// launchctl only lists services in the current process's domain. As a
+// result, only daemons are checked for being loaded when running as root,
+// and only agents are checked otherwise.
+func DetectDrift(options DiscoverOptions) (DriftReport, error) {
+	options.SkipLoadedCheck = true
+
+	services, err := Discover(options)
+	if err != nil {
+		return DriftReport{}, err
+	}
+
+	loaded, err := List()
+	if err != nil {
+		return DriftReport{}, err
+	}
+
+	markLoaded(services, loaded)
+
+	return detectDrift(services, loaded, options.kinds(), os.Geteuid() == 0), nil
+}
+
+func detectDrift(services []DiscoveredService, loaded []ListEntry, kinds []Kind, inSystemDomain bool) DriftReport {
+	report := DriftReport{
+		DuplicateLabels: make(map[string][]DiscoveredService),
+	}
+
+	labelsToServices := make(map[string][]DiscoveredService)
+
+	// hasFile contains the labels of every configuration file,
+	// including files that could not be parsed. The file name
+	// is used when the label could not be read.
+	hasFile := make(map[string]bool)
+
+	for _, service := range services {
+		if len(service.Label) > 0 {
+			hasFile[service.Label] = true
+		}
+
+		if service.ParseErr != nil {
+			hasFile[strings.TrimSuffix(path.Base(service.FilePath), plistFileSuffix)] = true
+			continue
+		}
+
+		if len(service.Label) == 0 {
+			continue
+		}
+
+		labelsToServices[service.Label] = append(labelsToServices[service.Label], service)
+
+		if strings.TrimSuffix(path.Base(service.FilePath), plistFileSuffix) != service.Label {
+			report.LabelMismatch = append(report.LabelMismatch, service)
+		}
+
+		if !service.Loaded && !service.Disabled && service.Kind.isSystemDomain() == inSystemDomain {
+			report.NotLoaded = append(report.NotLoaded, service)
+		}
+	}
+
+	for label, withLabel := range labelsToServices {
+		if len(withLabel) > 1 {
+			report.DuplicateLabels[label] = withLabel
+		}
+	}
+
+	if !discoversDomain(kinds, inSystemDomain) {
+		return report
+	}
+
+	for _, entry := range loaded {
+		if !hasFile[entry.Label] && !isSynthesizedLabel(entry.Label) {
+			report.NoConfigFile = append(report.NoConfigFile, entry)
+		}
+	}
+
+	return report
+}
+
+// discoversDomain returns true if the kinds include every kind of
+// service in the domain, excluding the bundled kinds.
+func discoversDomain(kinds []Kind, inSystemDomain bool) bool {
+	domainKinds := []Kind{UserAgent, GlobalAgent, SystemAgent}
+	if inSystemDomain {
+		domainKinds = []Kind{Daemon, SystemDaemon}
+	}
+
+	for _, domainKind := range domainKinds {
+		found := false
+		for _, kind := range kinds {
+			if kind == domainKind {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isSynthesizedLabel returns true if launchd created the service
+// without a configuration file (e.g., for an application that the
+// user opened).
+func isSynthesizedLabel(label string) bool {
+	for _, prefix := range synthesizedLabelPrefixes {
+		if strings.HasPrefix(label, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
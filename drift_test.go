@@ -0,0 +1,76 @@
+package launchctlutil
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDetectDrift(t *testing.T) {
+	services := []DiscoveredService{
+		{Label: "com.loaded", Kind: UserAgent, FilePath: "/a/com.loaded.plist", Loaded: true},
+		{Label: "com.notloaded", Kind: UserAgent, FilePath: "/a/com.notloaded.plist"},
+		{Label: "com.disabled", Kind: UserAgent, FilePath: "/a/com.disabled.plist", Disabled: true},
+		{Label: "com.daemon", Kind: Daemon, FilePath: "/b/com.daemon.plist"},
+		{Label: "com.mismatch", Kind: UserAgent, FilePath: "/a/com.other.plist", Loaded: true},
+		{Label: "com.loaded", Kind: GlobalAgent, FilePath: "/c/com.loaded.plist", Loaded: true},
+		{Label: "com.badvalue", Kind: UserAgent, FilePath: "/a/com.badvalue.plist", ParseErr: errors.New("bad")},
+		{Kind: UserAgent, FilePath: "/a/com.unreadable.plist", ParseErr: errors.New("bad")},
+	}
+
+	loaded := []ListEntry{
+		{Label: "com.loaded"},
+		{Label: "com.mismatch"},
+		{Label: "com.nofile"},
+		{Label: "com.badvalue"},
+		{Label: "com.unreadable"},
+		{Label: "application.com.example.App.1234.5678"},
+	}
+
+	report := detectDrift(services, loaded, []Kind{UserAgent, GlobalAgent, SystemAgent, Daemon}, false)
+
+	if !report.HasDrift() {
+		t.Fatal("report should contain drift")
+	}
+
+	if len(report.NotLoaded) != 1 || report.NotLoaded[0].Label != "com.notloaded" {
+		t.Fatalf("unexpected not loaded services - got %+v", report.NotLoaded)
+	}
+
+	if len(report.NoConfigFile) != 1 || report.NoConfigFile[0].Label != "com.nofile" {
+		t.Fatalf("unexpected services without a config file - got %+v", report.NoConfigFile)
+	}
+
+	if len(report.LabelMismatch) != 1 || report.LabelMismatch[0].Label != "com.mismatch" {
+		t.Fatalf("unexpected label mismatches - got %+v", report.LabelMismatch)
+	}
+
+	if len(report.DuplicateLabels) != 1 || len(report.DuplicateLabels["com.loaded"]) != 2 {
+		t.Fatalf("unexpected duplicate labels - got %+v", report.DuplicateLabels)
+	}
+}
+
+func TestDetectDriftRestrictedKinds(t *testing.T) {
+	services := []DiscoveredService{
+		{Label: "com.loaded", Kind: UserAgent, FilePath: "/a/com.loaded.plist", Loaded: true},
+	}
+
+	loaded := []ListEntry{
+		{Label: "com.loaded"},
+		{Label: "com.apple.example"},
+	}
+
+	report := detectDrift(services, loaded, []Kind{UserAgent}, false)
+	if report.HasDrift() {
+		t.Fatalf("loaded services of kinds that were not discovered should not be reported - got %+v", report)
+	}
+
+	report = detectDrift(services, loaded, []Kind{UserAgent, GlobalAgent, SystemAgent}, true)
+	if report.HasDrift() {
+		t.Fatalf("loaded services in a domain that was not discovered should not be reported - got %+v", report)
+	}
+
+	report = detectDrift(services, loaded, []Kind{UserAgent, GlobalAgent, SystemAgent}, false)
+	if len(report.NoConfigFile) != 1 || report.NoConfigFile[0].Label != "com.apple.example" {
+		t.Fatalf("unexpected services without a config file - got %+v", report.NoConfigFile)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/stephen-fox/launchctlutil"
+	"github.com/stephen-fox/launchctlutil/service"
+)
+
+func main() {
+	s, err := service.New(service.Options{
+		Label:        "com.testing.selfservice",
+		Kind:         launchctlutil.UserAgent,
+		KeepAlive:    true,
+		LogDirectory: "/tmp",
+	}, run)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	err = s.Main(os.Args[1:])
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+}
+
+func run(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("stopping")
+			return ctx.Err()
+		case <-ticker.C:
+			log.Println("working")
+		}
+	}
+}
@@ -1,3 +1,5 @@
 module github.com/stephen-fox/launchctlutil
 
 go 1.12
+
+require gopkg.in/yaml.v3 v3.0.1
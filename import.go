@@ -0,0 +1,47 @@
+package launchctlutil
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ImportWarning describes a setting that could not be converted, or
+// could only be approximated, when importing a service definition
+// from another format.
+type ImportWarning struct {
+	// Source identifies the file the setting came from (e.g.,
+	// "service" or "timer"). It may be empty.
+	Source string
+
+	// Line is the setting's line number, or zero if it is unknown.
+	Line int
+
+	// Setting is the name of the setting (e.g., "[Service] PrivateTmp").
+	Setting string
+
+	// Message explains how the setting was handled.
+	Message string
+}
+
+func (o ImportWarning) String() string {
+	var location []string
+
+	switch {
+	case len(o.Source) > 0 && o.Line > 0:
+		location = append(location, o.Source+":"+strconv.Itoa(o.Line))
+	case len(o.Source) > 0:
+		location = append(location, o.Source)
+	case o.Line > 0:
+		location = append(location, "line "+strconv.Itoa(o.Line))
+	}
+
+	if len(o.Setting) > 0 {
+		location = append(location, o.Setting)
+	}
+
+	if len(location) == 0 {
+		return o.Message
+	}
+
+	return strings.Join(location, ": ") + ": " + o.Message
+}
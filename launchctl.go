@@ -2,11 +2,7 @@ package launchctlutil
 
 import (
 	"fmt"
-	"io/ioutil"
 	"os"
-	"os/exec"
-	"os/user"
-	"runtime"
 	"strconv"
 	"strings"
 )
@@ -42,12 +38,26 @@ func (o StatusDetails) GotPid() bool {
 	return o.PidErr == nil
 }
 
+// ListEntry is a loaded launchd service as reported by "launchctl list".
+type ListEntry struct {
+	Label          string
+	Pid            int
+	LastExitStatus int
+}
+
+// IsRunning returns true if the launchd service has a PID.
+func (o ListEntry) IsRunning() bool {
+	return o.Pid > 0
+}
+
 const (
 	defaultLaunchctl          = "launchctl"
 	couldNotFindServicePrefix = "Could not find service "
 	lastExitStatusPrefix      = "\"LastExitStatus\" = "
 	pidPrefix                 = "\"PID\" = "
 	serviceListLineSuffix     = ";"
+	listHeaderPrefix          = "PID\t"
+	listNoValue               = "-"
 )
 
 var (
@@ -56,12 +66,13 @@ var (
 )
 
 // Install installs the provided service Configuration.
+//
+// GlobalAgent configurations are written to disk, but are not loaded.
+// launchd loads them for each user the next time the user logs in.
 func Install(configuration Configuration) error {
-	if configuration.GetKind() == Daemon {
-		err := isRoot()
-		if err != nil {
-			return err
-		}
+	runner, err := checkInstallable("install", configuration.GetKind())
+	if err != nil {
+		return err
 	}
 
 	configPath, err := configuration.GetFilePath()
@@ -73,14 +84,16 @@ func Install(configuration Configuration) error {
 	// Ignore errors because this may create false positives.
 	Remove(configPath, configuration.GetKind())
 
-	err = ioutil.WriteFile(configPath, []byte(configuration.GetContents()), 0600)
+	err = runner.WriteFile(configPath, []byte(configuration.GetContents()), 0600)
 	if err != nil {
 		return err
 	}
 
-	_, err = run("load", configPath)
-	if err != nil {
-		return err
+	if configuration.GetKind() != GlobalAgent {
+		_, err = run(runner, "load", configPath)
+		if err != nil {
+			return err
+		}
 	}
 
 	// Check that the LaunchAgent was installed using special logic because
@@ -93,7 +106,7 @@ func Install(configuration Configuration) error {
 	if !isInstalled {
 		// Try to remove the config file if the installation fails.
 		// Ignore errors because this may create false positives.
-		os.Remove(configPath)
+		runner.RemoveFile(configPath)
 		return fmt.Errorf("an unknown error occurred installing the laucnctl config")
 	}
 
@@ -101,20 +114,22 @@ func Install(configuration Configuration) error {
 }
 
 // Remove unloads and removes the specified service configuration file.
+// GlobalAgent configurations are only removed from disk because they
+// are not loaded into the current user's domain.
 func Remove(configPath string, kind Kind) error {
-	if kind == Daemon {
-		err := isRoot()
+	runner, err := checkInstallable("remove", kind)
+	if err != nil {
+		return err
+	}
+
+	if kind != GlobalAgent {
+		_, err = run(runner, "unload", configPath)
 		if err != nil {
 			return err
 		}
 	}
 
-	_, err := run("unload", configPath)
-	if err != nil {
-		return err
-	}
-
-	err = os.Remove(configPath)
+	err = runner.RemoveFile(configPath)
 	if err != nil {
 		return err
 	}
@@ -127,7 +142,7 @@ func Remove(configPath string, kind Kind) error {
 //
 // Warning: This call does not error if the specified service does not exist.
 func RemoveService(label string) error {
-	_, err := run("remove", label)
+	_, err := run(localRunner{}, "remove", label)
 	if err != nil {
 		return err
 	}
@@ -135,6 +150,94 @@ func RemoveService(label string) error {
 	return nil
 }
 
+// UninstallResult describes what Uninstall cleaned up.
+type UninstallResult struct {
+	// FilePath is the path to the service's configuration file.
+	FilePath string
+
+	// Unloaded is true if the service was loaded and was unloaded.
+	Unloaded bool
+
+	// FileRemoved is true if the configuration file existed and
+	// was removed.
+	FileRemoved bool
+}
+
+// Uninstall unloads the specified service and removes its configuration
+// file. The configuration file's path is derived from the label and kind.
+// Services with a configuration file but no loaded job, and loaded jobs
+// with no configuration file, are both cleaned up. No error is returned
+// if neither exists.
+//
+// The BundledAgent and BundledDaemon kinds are not supported because
+// their configuration file paths cannot be derived from a label. Use
+// Remove for those instead.
+func Uninstall(label string, kind Kind) (UninstallResult, error) {
+	runner, err := checkInstallable("uninstall", kind)
+	if err != nil {
+		return UninstallResult{}, err
+	}
+
+	configPath, err := (&configuration{label: label, kind: kind}).GetFilePath()
+	if err != nil {
+		return UninstallResult{}, err
+	}
+
+	return uninstallWith(runner, label, kind, configPath)
+}
+
+func uninstallWith(runner Runner, label string, kind Kind, configPath string) (UninstallResult, error) {
+	result := UninstallResult{
+		FilePath: configPath,
+	}
+
+	_, statErr := os.Stat(configPath)
+	hasFile := statErr == nil
+
+	// Global agents are not loaded into the current user's domain.
+	if kind != GlobalAgent {
+		loaded, err := isLoaded(runner, label)
+		if err != nil {
+			return result, err
+		}
+
+		if loaded {
+			if hasFile {
+				_, err = run(runner, "unload", configPath)
+			} else {
+				_, err = run(runner, "remove", label)
+			}
+			if err != nil {
+				return result, err
+			}
+
+			result.Unloaded = true
+		}
+	}
+
+	if hasFile {
+		err := runner.RemoveFile(configPath)
+		if err != nil {
+			return result, err
+		}
+
+		result.FileRemoved = true
+	}
+
+	if result.Unloaded {
+		stillLoaded, err := isLoaded(runner, label)
+		if err != nil {
+			return result, err
+		}
+
+		if stillLoaded {
+			return result, fmt.Errorf("service '%s' is still loaded after being uninstalled", label)
+		}
+	}
+
+	return result, nil
+}
+
 // IsInstalled is a wrapper for Configuration.IsInstalled().
 func IsInstalled(configuration Configuration) (isInstalled bool, err error) {
 	return configuration.IsInstalled()
@@ -142,14 +245,12 @@ func IsInstalled(configuration Configuration) (isInstalled bool, err error) {
 
 // Start starts the specified launchd service.
 func Start(label string, kind Kind) error {
-	if kind == Daemon {
-		err := isRoot()
-		if err != nil {
-			return err
-		}
+	runner, err := checkPrivileges("start", kind)
+	if err != nil {
+		return err
 	}
 
-	_, err := run("start", label)
+	_, err = run(runner, "start", label)
 	if err != nil {
 		return err
 	}
@@ -159,14 +260,12 @@ func Start(label string, kind Kind) error {
 
 // Stop stops the specified launchd service.
 func Stop(label string, kind Kind) error {
-	if kind == Daemon {
-		err := isRoot()
-		if err != nil {
-			return err
-		}
+	runner, err := checkPrivileges("stop", kind)
+	if err != nil {
+		return err
 	}
 
-	_, err := run("stop", label)
+	_, err = run(runner, "stop", label)
 	if err != nil {
 		return err
 	}
@@ -174,9 +273,37 @@ func Stop(label string, kind Kind) error {
 	return nil
 }
 
+// List returns the launchd services that are loaded in the current
+// process's domain. Daemons are only included when running as root.
+func List() ([]ListEntry, error) {
+	return listWith(localRunner{})
+}
+
 // CurrentStatus returns the current status of the specified launchd service.
+// Only the current process's launchd domain is checked, so daemons are
+// reported as not installed unless the process is root. Use
+// CurrentStatusForKind to check the domain of a specific Kind.
 func CurrentStatus(label string) (StatusDetails, error) {
-	output, err := run("list", label)
+	return statusWith(localRunner{}, label)
+}
+
+// CurrentStatusForKind returns the current status of the specified launchd
+// service of the specified Kind. Daemons are checked in the system domain,
+// which requires root privileges or a PrivilegedRunner. A *PrivilegeError
+// is returned otherwise.
+func CurrentStatusForKind(label string, kind Kind) (StatusDetails, error) {
+	runner, err := checkPrivileges("status", kind)
+	if err != nil {
+		return StatusDetails{
+			Status: Unknown,
+		}, err
+	}
+
+	return statusWith(runner, label)
+}
+
+func statusWith(runner Runner, label string) (StatusDetails, error) {
+	output, err := run(runner, "list", label)
 	if err != nil {
 		if strings.HasPrefix(output, couldNotFindServicePrefix) {
 			return StatusDetails{
@@ -221,6 +348,69 @@ func CurrentStatus(label string) (StatusDetails, error) {
 	return details, nil
 }
 
+func listWith(runner Runner) ([]ListEntry, error) {
+	output, err := run(runner, "list")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseList(output)
+}
+
+func isLoaded(runner Runner, label string) (bool, error) {
+	entries, err := listWith(runner)
+	if err != nil {
+		return false, err
+	}
+
+	for _, entry := range entries {
+		if entry.Label == label {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func parseList(output string) ([]ListEntry, error) {
+	var entries []ListEntry
+
+	for _, l := range strings.Split(output, "\n") {
+		if len(strings.TrimSpace(l)) == 0 || strings.HasPrefix(l, listHeaderPrefix) {
+			continue
+		}
+
+		fields := strings.SplitN(l, "\t", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected launchctl list line: '%s'", l)
+		}
+
+		entry := ListEntry{
+			Label: fields[2],
+		}
+
+		if fields[0] != listNoValue {
+			pid, err := strconv.Atoi(fields[0])
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse PID for '%s' - %s", entry.Label, err.Error())
+			}
+			entry.Pid = pid
+		}
+
+		if fields[1] != listNoValue {
+			exit, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse exit status for '%s' - %s", entry.Label, err.Error())
+			}
+			entry.LastExitStatus = exit
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
 func getPid(lineWithoutLeadingSpaces string) (int, error) {
 	lineWithoutLeadingSpaces = strings.TrimPrefix(lineWithoutLeadingSpaces, pidPrefix)
 	lineWithoutLeadingSpaces = strings.TrimSuffix(lineWithoutLeadingSpaces, serviceListLineSuffix)
@@ -245,29 +435,8 @@ func getLastExitStatus(lineWithoutLeadingSpaces string) (int, error) {
 	return exit, nil
 }
 
-func isRoot() error {
-	currentUser, err := user.Current()
-	if err != nil {
-		// For whatever reason, 'user.Current()' throws a "not
-		// implemented error" when running as a launch service
-		// on macOS.
-		if runtime.GOOS == "darwin" && strings.Contains(err.Error(), "Current not implemented on") {
-			return nil
-		}
-		return fmt.Errorf("failed to check if current user is root - %s", err.Error())
-	}
-
-	if currentUser.Username == "root" {
-		return nil
-	}
-
-	return fmt.Errorf("root privileges are required to do this")
-}
-
-func run(args... string) (output string, err error) {
-	command := exec.Command(ExePath, args...)
-	raw, err := command.CombinedOutput()
-	output = string(raw)
+func run(runner Runner, args ...string) (output string, err error) {
+	output, err = runner.Launchctl(args...)
 	if err != nil {
 		return output, fmt.Errorf("%s - output: %s", err.Error(), output)
 	}
@@ -2,7 +2,11 @@ package launchctlutil
 
 import (
 	"errors"
+	"io/ioutil"
+	"os"
+	"path"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -94,3 +98,155 @@ func TestStatusDetails_GotPid(t *testing.T) {
 		t.Fatal("got a PID when there was an error")
 	}
 }
+
+func TestParseList(t *testing.T) {
+	entries, err := parseList("PID\tStatus\tLabel\n" +
+		"-\t0\tcom.apple.SafariHistoryServiceAgent\n" +
+		"33385\t-15\tcom.apple.Finder\n")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries - got %d", len(entries))
+	}
+
+	if entries[0].IsRunning() {
+		t.Fatal("first entry should not be running")
+	}
+
+	if entries[1].Label != "com.apple.Finder" || entries[1].Pid != 33385 || entries[1].LastExitStatus != -15 {
+		t.Fatalf("unexpected second entry - got %+v", entries[1])
+	}
+}
+
+func TestUninstallWith(t *testing.T) {
+	dir, err := ioutil.TempDir("", "launchctlutil-test-")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	const label = "com.testing"
+	configPath := path.Join(dir, label+plistFileSuffix)
+
+	tests := []struct {
+		name         string
+		kind         Kind
+		hasFile      bool
+		loaded       bool
+		unloadErr    error
+		ignoreUnload bool
+		exp          UninstallResult
+		expErr       bool
+		expCommands  []string
+	}{
+		{
+			name:        "loaded with file",
+			hasFile:     true,
+			loaded:      true,
+			exp:         UninstallResult{Unloaded: true, FileRemoved: true},
+			expCommands: []string{"list", "unload " + configPath, "list"},
+		},
+		{
+			name:        "file not loaded",
+			hasFile:     true,
+			exp:         UninstallResult{FileRemoved: true},
+			expCommands: []string{"list"},
+		},
+		{
+			name:        "loaded without file",
+			loaded:      true,
+			exp:         UninstallResult{Unloaded: true},
+			expCommands: []string{"list", "remove " + label, "list"},
+		},
+		{
+			name:        "not installed",
+			expCommands: []string{"list"},
+		},
+		{
+			name:    "global agent",
+			kind:    GlobalAgent,
+			hasFile: true,
+			loaded:  true,
+			exp:     UninstallResult{FileRemoved: true},
+		},
+		{
+			name:        "unload fails",
+			hasFile:     true,
+			loaded:      true,
+			unloadErr:   errors.New("exit status 1"),
+			expErr:      true,
+			expCommands: []string{"list", "unload " + configPath},
+		},
+		{
+			name:         "still loaded",
+			hasFile:      true,
+			loaded:       true,
+			ignoreUnload: true,
+			exp:          UninstallResult{Unloaded: true, FileRemoved: true},
+			expErr:       true,
+			expCommands:  []string{"list", "unload " + configPath, "list"},
+		},
+	}
+
+	for _, test := range tests {
+		os.Remove(configPath)
+		if test.hasFile {
+			err := ioutil.WriteFile(configPath, []byte("test"), 0600)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+		}
+
+		runner := &testRunner{
+			loaded:       make(map[string]bool),
+			unloadErr:    test.unloadErr,
+			ignoreUnload: test.ignoreUnload,
+		}
+		if test.loaded {
+			runner.loaded[label] = true
+		}
+
+		result, err := uninstallWith(runner, label, test.kind, configPath)
+		if (err != nil) != test.expErr {
+			t.Fatalf("%s: unexpected error - got %v", test.name, err)
+		}
+
+		test.exp.FilePath = configPath
+		if result != test.exp {
+			t.Fatalf("%s: expected %+v - got %+v", test.name, test.exp, result)
+		}
+
+		if strings.Join(runner.commands, ", ") != strings.Join(test.expCommands, ", ") {
+			t.Fatalf("%s: expected commands %q - got %q", test.name, test.expCommands, runner.commands)
+		}
+
+		_, statErr := os.Stat(configPath)
+		if test.hasFile && test.unloadErr == nil && statErr == nil {
+			t.Fatalf("%s: the configuration file should be removed", test.name)
+		}
+	}
+}
+
+func TestStatusWith(t *testing.T) {
+	runner := &testRunner{loaded: map[string]bool{"com.testing": true}}
+
+	details, err := statusWith(runner, "com.testing")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if details.Status != NotRunning || details.LastExitStatus != 256 {
+		t.Fatalf("the service should not be running - got %+v", details)
+	}
+
+	details, err = statusWith(runner, "com.other")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if details.Status != NotInstalled {
+		t.Fatalf("the service should not be installed - got %+v", details)
+	}
+}
@@ -0,0 +1,31 @@
+package launchctlutil
+
+// MachService is a Mach service that a launchd service advertises.
+type MachService struct {
+	// ResetAtClose makes launchd reset the service's port when the
+	// service closes it, which lets clients detect that the service
+	// has exited.
+	ResetAtClose bool
+
+	// HideUntilCheckIn hides the service from lookups until the
+	// service checks in with launchd.
+	HideUntilCheckIn bool
+}
+
+func (o MachService) plistValue() PlistValue {
+	if !o.ResetAtClose && !o.HideUntilCheckIn {
+		return PlistBool(true)
+	}
+
+	dict := PlistDict{}
+
+	if o.ResetAtClose {
+		dict = append(dict, PlistEntry{Key: "ResetAtClose", Value: PlistBool(true)})
+	}
+
+	if o.HideUntilCheckIn {
+		dict = append(dict, PlistEntry{Key: "HideUntilCheckIn", Value: PlistBool(true)})
+	}
+
+	return dict
+}
@@ -0,0 +1,695 @@
+package launchctlutil
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	defaultPlutil     = "plutil"
+	binaryPlistPrefix = "bplist"
+
+	oneIndent = "    "
+
+	openPlist   = "<plist version=\"1.0\">\n"
+	closePlist  = "</plist>\n"
+	openKey     = "<key>"
+	closeKey    = "</key>\n"
+	openDict    = "<dict>\n"
+	closeDict   = "</dict>\n"
+	openArray   = "<array>\n"
+	closeArray  = "</array>\n"
+	openString  = "<string>"
+	closeString = "</string>\n"
+	openInt     = "<integer>"
+	closeInt    = "</integer>\n"
+	newLine     = "\n"
+)
+
+var (
+	// PlutilPath is the path to the plutil CLI application. It is used
+	// to convert binary property lists to XML.
+	PlutilPath = defaultPlutil
+
+	// ErrBinaryPlist is returned when a binary property list is decoded.
+	// Binary property lists can be converted to XML using plutil.
+	ErrBinaryPlist = errors.New("binary property lists are not supported")
+)
+
+// PlistValue is a value in a property list. It is one of PlistString,
+// PlistInteger, PlistReal, PlistBool, PlistDate, PlistData, PlistArray,
+// or PlistDict.
+type PlistValue interface {
+	// PlistType returns the name of the value's property list
+	// element (e.g., "string" or "dict").
+	PlistType() string
+}
+
+// PlistString is a property list string.
+type PlistString string
+
+func (o PlistString) PlistType() string {
+	return "string"
+}
+
+// PlistInteger is a property list integer.
+type PlistInteger int64
+
+func (o PlistInteger) PlistType() string {
+	return "integer"
+}
+
+// PlistReal is a property list floating point number.
+type PlistReal float64
+
+func (o PlistReal) PlistType() string {
+	return "real"
+}
+
+// PlistBool is a property list boolean.
+type PlistBool bool
+
+func (o PlistBool) PlistType() string {
+	return "bool"
+}
+
+// PlistDate is a property list date.
+type PlistDate time.Time
+
+func (o PlistDate) PlistType() string {
+	return "date"
+}
+
+// PlistData is a property list binary blob.
+type PlistData []byte
+
+func (o PlistData) PlistType() string {
+	return "data"
+}
+
+// PlistArray is a property list array.
+type PlistArray []PlistValue
+
+func (o PlistArray) PlistType() string {
+	return "array"
+}
+
+// PlistDict is a property list dictionary. Entries are kept in the
+// order they were added or decoded.
+type PlistDict []PlistEntry
+
+// PlistEntry is a key and value pair in a PlistDict.
+type PlistEntry struct {
+	Key   string
+	Value PlistValue
+}
+
+func (o PlistDict) PlistType() string {
+	return "dict"
+}
+
+// Get returns the value of the specified key.
+func (o PlistDict) Get(key string) (PlistValue, bool) {
+	for _, entry := range o {
+		if entry.Key == key {
+			return entry.Value, true
+		}
+	}
+
+	return nil, false
+}
+
+// GetString returns the value of the specified key if it is
+// a PlistString.
+func (o PlistDict) GetString(key string) (string, bool) {
+	value, _ := o.Get(key)
+	str, ok := value.(PlistString)
+	return string(str), ok
+}
+
+// GetInteger returns the value of the specified key if it is
+// a PlistInteger.
+func (o PlistDict) GetInteger(key string) (int64, bool) {
+	value, _ := o.Get(key)
+	i, ok := value.(PlistInteger)
+	return int64(i), ok
+}
+
+// GetBool returns the value of the specified key if it is a PlistBool.
+func (o PlistDict) GetBool(key string) (bool, bool) {
+	value, _ := o.Get(key)
+	b, ok := value.(PlistBool)
+	return bool(b), ok
+}
+
+// GetStrings returns the value of the specified key if it is
+// a PlistArray that only contains PlistString values.
+func (o PlistDict) GetStrings(key string) ([]string, bool) {
+	value, _ := o.Get(key)
+	array, ok := value.(PlistArray)
+	if !ok {
+		return nil, false
+	}
+
+	var strs []string
+	for _, v := range array {
+		str, ok := v.(PlistString)
+		if !ok {
+			return nil, false
+		}
+		strs = append(strs, string(str))
+	}
+
+	return strs, true
+}
+
+type plistTypeError struct {
+	key      string
+	expected string
+	got      string
+}
+
+func (o *plistTypeError) Error() string {
+	return "expected '" + o.key + "' to be a " + o.expected + " - got " + o.got
+}
+
+// validatePlistKeys returns a non-nil error if the dict contains an empty
+// or duplicate key, or if any of its values are invalid. The name is
+// used to identify the dict in error messages.
+func validatePlistKeys(name string, dict PlistDict) error {
+	keys := make(map[string]bool)
+
+	for _, entry := range dict {
+		if len(entry.Key) == 0 {
+			return fmt.Errorf("'%s' contains an empty key", name)
+		}
+
+		if keys[entry.Key] {
+			return fmt.Errorf("'%s' contains duplicate key '%s'", name, entry.Key)
+		}
+		keys[entry.Key] = true
+
+		err := validatePlistValue(name+"."+entry.Key, entry.Value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validatePlistValue returns a non-nil error if the value, or any
+// value nested in it, is nil.
+func validatePlistValue(name string, value PlistValue) error {
+	switch v := value.(type) {
+	case nil:
+		return fmt.Errorf("'%s' has no value", name)
+	case PlistArray:
+		for i, element := range v {
+			err := validatePlistValue(name+"["+strconv.Itoa(i)+"]", element)
+			if err != nil {
+				return err
+			}
+		}
+	case PlistDict:
+		return validatePlistKeys(name, v)
+	}
+
+	return nil
+}
+
+// DecodePlist decodes an XML property list.
+func DecodePlist(data []byte) (PlistValue, error) {
+	if bytes.HasPrefix(data, []byte(binaryPlistPrefix)) {
+		return nil, ErrBinaryPlist
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				return nil, errors.New("property list does not contain a value")
+			}
+			return nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local == "plist" {
+			continue
+		}
+
+		return decodePlistValue(decoder, start)
+	}
+}
+
+// ReadPlistFile reads and decodes the specified property list file.
+// Binary property lists are converted to XML using plutil.
+func ReadPlistFile(filePath string) (PlistValue, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(data, []byte(binaryPlistPrefix)) {
+		data, err = exec.Command(PlutilPath, "-convert", "xml1", "-o", "-", filePath).Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert binary property list to xml - %s", err.Error())
+		}
+	}
+
+	return DecodePlist(data)
+}
+
+func decodePlistValue(decoder *xml.Decoder, start xml.StartElement) (PlistValue, error) {
+	switch start.Name.Local {
+	case "string":
+		var str string
+		err := decoder.DecodeElement(&str, &start)
+		if err != nil {
+			return nil, err
+		}
+		return PlistString(str), nil
+	case "integer":
+		var raw string
+		err := decoder.DecodeElement(&raw, &start)
+		if err != nil {
+			return nil, err
+		}
+		i, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse integer - %s", err.Error())
+		}
+		return PlistInteger(i), nil
+	case "real":
+		var raw string
+		err := decoder.DecodeElement(&raw, &start)
+		if err != nil {
+			return nil, err
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse real - %s", err.Error())
+		}
+		return PlistReal(f), nil
+	case "true", "false":
+		err := decoder.Skip()
+		if err != nil {
+			return nil, err
+		}
+		return PlistBool(start.Name.Local == "true"), nil
+	case "date":
+		var raw string
+		err := decoder.DecodeElement(&raw, &start)
+		if err != nil {
+			return nil, err
+		}
+		t, err := time.Parse(time.RFC3339, strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse date - %s", err.Error())
+		}
+		return PlistDate(t), nil
+	case "data":
+		var raw string
+		err := decoder.DecodeElement(&raw, &start)
+		if err != nil {
+			return nil, err
+		}
+		b, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(raw), ""))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse data - %s", err.Error())
+		}
+		return PlistData(b), nil
+	case "array":
+		array := PlistArray{}
+		for {
+			child, done, err := nextPlistElement(decoder)
+			if err != nil {
+				return nil, err
+			}
+			if done {
+				return array, nil
+			}
+			value, err := decodePlistValue(decoder, child)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+	case "dict":
+		dict := PlistDict{}
+		for {
+			keyElement, done, err := nextPlistElement(decoder)
+			if err != nil {
+				return nil, err
+			}
+			if done {
+				return dict, nil
+			}
+			if keyElement.Name.Local != "key" {
+				return nil, fmt.Errorf("expected a dict key - got '%s'", keyElement.Name.Local)
+			}
+			var key string
+			err = decoder.DecodeElement(&key, &keyElement)
+			if err != nil {
+				return nil, err
+			}
+
+			valueElement, done, err := nextPlistElement(decoder)
+			if err != nil {
+				return nil, err
+			}
+			if done {
+				return nil, fmt.Errorf("dict key '%s' is missing a value", key)
+			}
+			value, err := decodePlistValue(decoder, valueElement)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode value of '%s' - %s", key, err.Error())
+			}
+			dict = append(dict, PlistEntry{Key: key, Value: value})
+		}
+	default:
+		return nil, fmt.Errorf("unknown property list element '%s'", start.Name.Local)
+	}
+}
+
+// nextPlistElement returns the next child element of the current
+// element. It returns true if the current element ended instead.
+func nextPlistElement(decoder *xml.Decoder) (xml.StartElement, bool, error) {
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				return xml.StartElement{}, false, io.ErrUnexpectedEOF
+			}
+			return xml.StartElement{}, false, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			return t, false, nil
+		case xml.EndElement:
+			return xml.StartElement{}, true, nil
+		}
+	}
+}
+
+const (
+	plistHeader = "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n" +
+		"<!DOCTYPE plist PUBLIC \"-//Apple//DTD PLIST 1.0//EN\" " +
+		"\"http://www.apple.com/DTDs/PropertyList-1.0.dtd\">\n"
+)
+
+// PlistFormat configures how XML property lists are encoded. The zero
+// value is the format used by ConfigurationBuilder and NewConfiguration.
+type PlistFormat struct {
+	// Indent is the string used for each level of indentation (e.g.,
+	// "\t"). It defaults to four spaces.
+	Indent string
+
+	// SortKeys sorts the keys of every dictionary. By default, keys
+	// are encoded in the order they were added.
+	SortKeys bool
+
+	// Canonical encodes property lists exactly like
+	// "plutil -convert xml1" does. Keys are sorted, lines are indented
+	// with tabs, the root value is not indented, data is wrapped at 76
+	// characters, and reals are written with 17 significant digits.
+	// The other settings are ignored when it is true.
+	Canonical bool
+}
+
+// indent returns the string used for one level of indentation.
+func (o PlistFormat) indent() string {
+	switch {
+	case o.Canonical:
+		return "\t"
+	case len(o.Indent) > 0:
+		return o.Indent
+	}
+
+	return oneIndent
+}
+
+// EncodePlistFormat returns the value as an XML property list document
+// encoded in the specified format.
+func EncodePlistFormat(value PlistValue, format PlistFormat) []byte {
+	return []byte(encodePlistDocumentFormat(value, format))
+}
+
+// encodePlistDocument returns a complete XML property list document
+// containing the value.
+func encodePlistDocument(value PlistValue) string {
+	return encodePlistDocumentFormat(value, PlistFormat{})
+}
+
+func encodePlistDocumentFormat(value PlistValue, format PlistFormat) string {
+	encoder := &plistEncoder{
+		format: format,
+		indent: format.indent(),
+	}
+
+	depth := 1
+	if format.Canonical {
+		depth = 0
+	}
+
+	encoder.buffer.WriteString(plistHeader)
+	encoder.buffer.WriteString(openPlist)
+	encoder.encode(value, depth)
+	encoder.buffer.WriteString(closePlist)
+
+	return encoder.buffer.String()
+}
+
+// plistEncoder writes XML property lists.
+type plistEncoder struct {
+	buffer bytes.Buffer
+	format PlistFormat
+	indent string
+}
+
+// encode writes the XML representation of a value to the buffer,
+// indenting each line by the specified depth.
+func (o *plistEncoder) encode(value PlistValue, depth int) {
+	indent := strings.Repeat(o.indent, depth)
+
+	switch v := value.(type) {
+	case PlistString:
+		o.buffer.WriteString(concat(indent, openString, escapePlistString(string(v)), closeString))
+	case PlistInteger:
+		o.buffer.WriteString(concat(indent, openInt, strconv.FormatInt(int64(v), 10), closeInt))
+	case PlistReal:
+		o.buffer.WriteString(concat(indent, "<real>", o.formatReal(float64(v)), "</real>\n"))
+	case PlistBool:
+		o.buffer.WriteString(concat(indent, boolToXml(bool(v)), newLine))
+	case PlistDate:
+		o.buffer.WriteString(concat(indent, "<date>", time.Time(v).UTC().Format(time.RFC3339), "</date>\n"))
+	case PlistData:
+		encoded := base64.StdEncoding.EncodeToString(v)
+		if !o.format.Canonical {
+			o.buffer.WriteString(concat(indent, "<data>", encoded, "</data>\n"))
+			return
+		}
+		o.buffer.WriteString(concat(indent, "<data>\n"))
+		for _, line := range canonicalDataLines(encoded, depth) {
+			o.buffer.WriteString(concat(indent, line, newLine))
+		}
+		o.buffer.WriteString(concat(indent, "</data>\n"))
+	case PlistArray:
+		if len(v) == 0 {
+			o.buffer.WriteString(concat(indent, "<array/>\n"))
+			return
+		}
+		o.buffer.WriteString(concat(indent, openArray))
+		for _, element := range v {
+			o.encode(element, depth+1)
+		}
+		o.buffer.WriteString(concat(indent, closeArray))
+	case PlistDict:
+		if len(v) == 0 {
+			o.buffer.WriteString(concat(indent, "<dict/>\n"))
+			return
+		}
+		if o.format.SortKeys || o.format.Canonical {
+			v = sortedPlistDict(v)
+		}
+		o.buffer.WriteString(concat(indent, openDict))
+		for _, entry := range v {
+			o.buffer.WriteString(concat(indent, o.indent, openKey, escapePlistString(entry.Key), closeKey))
+			o.encode(entry.Value, depth+1)
+		}
+		o.buffer.WriteString(concat(indent, closeDict))
+	}
+}
+
+// formatReal formats a real. The canonical form matches
+// CoreFoundation's "%.17g" formatting.
+func (o *plistEncoder) formatReal(f float64) string {
+	if !o.format.Canonical {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+
+	switch {
+	case math.IsNaN(f):
+		return "nan"
+	case math.IsInf(f, 1):
+		return "+infinity"
+	case math.IsInf(f, -1):
+		return "-infinity"
+	case f == 0:
+		return "0.0"
+	}
+
+	return strconv.FormatFloat(f, 'g', 17, 64)
+}
+
+// canonicalDataLines splits base64 data into lines the way
+// CoreFoundation does. Each line is 76 characters long, minus 8
+// characters for each level of indentation, up to 8 levels.
+func canonicalDataLines(encoded string, depth int) []string {
+	if depth > 8 {
+		depth = 8
+	}
+
+	lineLength := 76 - 8*depth
+
+	var lines []string
+	for len(encoded) > lineLength {
+		lines = append(lines, encoded[:lineLength])
+		encoded = encoded[lineLength:]
+	}
+
+	if len(encoded) > 0 {
+		lines = append(lines, encoded)
+	}
+
+	return lines
+}
+
+// sortedPlistDict returns a copy of the dictionary with its keys sorted
+// by their UTF-16 code units, which is the order CoreFoundation uses.
+func sortedPlistDict(dict PlistDict) PlistDict {
+	sorted := make(PlistDict, len(dict))
+	copy(sorted, dict)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a := utf16.Encode([]rune(sorted[i].Key))
+		b := utf16.Encode([]rune(sorted[j].Key))
+
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+
+		return len(a) < len(b)
+	})
+
+	return sorted
+}
+
+// escapePlistString escapes the characters that cannot appear in XML
+// character data.
+func escapePlistString(str string) string {
+	str = strings.Replace(str, "&", "&amp;", -1)
+	str = strings.Replace(str, "<", "&lt;", -1)
+	return strings.Replace(str, ">", "&gt;", -1)
+}
+
+// PlistChange is a difference between the top-level keys of two
+// property list dictionaries.
+type PlistChange struct {
+	Key string
+
+	// Old is the key's original value. It is nil if the key was added.
+	Old PlistValue
+
+	// New is the key's new value. It is nil if the key was removed.
+	New PlistValue
+}
+
+// DiffPlistDicts returns the top-level keys whose values differ between
+// the two dictionaries. Changed and removed keys are reported in the order
+// they appear in old, followed by added keys in the order they appear
+// in updated.
+func DiffPlistDicts(old PlistDict, updated PlistDict) []PlistChange {
+	var changes []PlistChange
+
+	for _, entry := range old {
+		newValue, ok := updated.Get(entry.Key)
+		if !ok {
+			changes = append(changes, PlistChange{Key: entry.Key, Old: entry.Value})
+			continue
+		}
+
+		if !PlistValuesEqual(entry.Value, newValue) {
+			changes = append(changes, PlistChange{Key: entry.Key, Old: entry.Value, New: newValue})
+		}
+	}
+
+	for _, entry := range updated {
+		_, ok := old.Get(entry.Key)
+		if !ok {
+			changes = append(changes, PlistChange{Key: entry.Key, New: entry.Value})
+		}
+	}
+
+	return changes
+}
+
+// PlistValuesEqual returns true if the two values are of the same type
+// and are equal. The order of keys in dictionaries is not significant.
+func PlistValuesEqual(a PlistValue, b PlistValue) bool {
+	switch va := a.(type) {
+	case PlistDate:
+		vb, ok := b.(PlistDate)
+		return ok && time.Time(va).Equal(time.Time(vb))
+	case PlistData:
+		vb, ok := b.(PlistData)
+		return ok && bytes.Equal(va, vb)
+	case PlistArray:
+		vb, ok := b.(PlistArray)
+		if !ok || len(va) != len(vb) {
+			return false
+		}
+		for i := range va {
+			if !PlistValuesEqual(va[i], vb[i]) {
+				return false
+			}
+		}
+		return true
+	case PlistDict:
+		vb, ok := b.(PlistDict)
+		if !ok || len(va) != len(vb) {
+			return false
+		}
+		for _, entry := range va {
+			value, ok := vb.Get(entry.Key)
+			if !ok || !PlistValuesEqual(entry.Value, value) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+// EncodePlist returns the value as an XML property list document.
+func EncodePlist(value PlistValue) []byte {
+	return []byte(encodePlistDocument(value))
+}
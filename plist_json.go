@@ -0,0 +1,302 @@
+package launchctlutil
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	jsonDateKey = "$date"
+	jsonDataKey = "$data"
+	jsonDictKey = "$dict"
+)
+
+// EncodePlistJSON returns the JSON representation of a property list value.
+// The representation is lossless; DecodePlistJSON returns the same value.
+//
+// Strings, booleans, arrays, and dictionaries are encoded as their JSON
+// equivalents, and dictionary keys keep their order. Integers are encoded
+// as numbers without a fraction or exponent, while reals always have
+// one (e.g., 1.0). Dates and data are encoded as objects with a single
+// key:
+//
+//	{"$date": "2006-01-02T15:04:05Z"}
+//	{"$data": "aGVsbG8="}
+//
+// A dictionary whose first key is "$date", "$data", or "$dict" is wrapped
+// in a "$dict" object so it is not mistaken for one of these objects.
+// Reals that are NaN or infinite cannot be encoded.
+func EncodePlistJSON(value PlistValue) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	err := encodePlistJSONValue(&buffer, value, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer.WriteString(newLine)
+
+	return buffer.Bytes(), nil
+}
+
+func encodePlistJSONValue(buffer *bytes.Buffer, value PlistValue, depth int) error {
+	indent := strings.Repeat("  ", depth)
+
+	switch v := value.(type) {
+	case PlistString:
+		buffer.WriteString(jsonString(string(v)))
+	case PlistInteger:
+		buffer.WriteString(strconv.FormatInt(int64(v), 10))
+	case PlistReal:
+		f := float64(v)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("real value %v cannot be encoded as JSON", f)
+		}
+		buffer.WriteString(formatPlistReal(f))
+	case PlistBool:
+		buffer.WriteString(strconv.FormatBool(bool(v)))
+	case PlistDate:
+		buffer.WriteString(concat("{", jsonString(jsonDateKey), ": ",
+			jsonString(time.Time(v).UTC().Format(time.RFC3339)), "}"))
+	case PlistData:
+		buffer.WriteString(concat("{", jsonString(jsonDataKey), ": ",
+			jsonString(base64.StdEncoding.EncodeToString(v)), "}"))
+	case PlistArray:
+		if len(v) == 0 {
+			buffer.WriteString("[]")
+			return nil
+		}
+		buffer.WriteString("[\n")
+		for i, element := range v {
+			buffer.WriteString(indent + "  ")
+			err := encodePlistJSONValue(buffer, element, depth+1)
+			if err != nil {
+				return err
+			}
+			if i < len(v)-1 {
+				buffer.WriteString(",")
+			}
+			buffer.WriteString(newLine)
+		}
+		buffer.WriteString(indent + "]")
+	case PlistDict:
+		if len(v) > 0 && isReservedJSONKey(v[0].Key) {
+			buffer.WriteString(concat("{", jsonString(jsonDictKey), ": "))
+			err := encodePlistJSONDict(buffer, v, depth)
+			if err != nil {
+				return err
+			}
+			buffer.WriteString("}")
+			return nil
+		}
+		return encodePlistJSONDict(buffer, v, depth)
+	default:
+		return fmt.Errorf("unsupported property list value %T", value)
+	}
+
+	return nil
+}
+
+func encodePlistJSONDict(buffer *bytes.Buffer, dict PlistDict, depth int) error {
+	if len(dict) == 0 {
+		buffer.WriteString("{}")
+		return nil
+	}
+
+	indent := strings.Repeat("  ", depth)
+
+	buffer.WriteString("{\n")
+	for i, entry := range dict {
+		buffer.WriteString(concat(indent, "  ", jsonString(entry.Key), ": "))
+		err := encodePlistJSONValue(buffer, entry.Value, depth+1)
+		if err != nil {
+			return err
+		}
+		if i < len(dict)-1 {
+			buffer.WriteString(",")
+		}
+		buffer.WriteString(newLine)
+	}
+	buffer.WriteString(indent + "}")
+
+	return nil
+}
+
+// DecodePlistJSON decodes the JSON representation of a property list
+// value. See EncodePlistJSON for details.
+func DecodePlistJSON(data []byte) (PlistValue, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	value, err := decodePlistJSONValue(decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = decoder.Token()
+	if err != io.EOF {
+		return nil, errors.New("unexpected data after the top-level value")
+	}
+
+	return value, nil
+}
+
+func decodePlistJSONValue(decoder *json.Decoder) (PlistValue, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := token.(type) {
+	case string:
+		return PlistString(t), nil
+	case bool:
+		return PlistBool(t), nil
+	case json.Number:
+		if strings.ContainsAny(t.String(), ".eE") {
+			f, err := t.Float64()
+			if err != nil {
+				return nil, fmt.Errorf("invalid real '%s' - %s", t, err.Error())
+			}
+			return PlistReal(f), nil
+		}
+
+		i, err := t.Int64()
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer '%s' - %s", t, err.Error())
+		}
+		return PlistInteger(i), nil
+	case nil:
+		return nil, errors.New("null has no property list equivalent")
+	case json.Delim:
+		if t == '[' {
+			array := PlistArray{}
+			for decoder.More() {
+				element, err := decodePlistJSONValue(decoder)
+				if err != nil {
+					return nil, err
+				}
+				array = append(array, element)
+			}
+
+			_, err = decoder.Token()
+			return array, err
+		}
+
+		return decodePlistJSONObject(decoder, false)
+	}
+
+	return nil, fmt.Errorf("unexpected JSON token %v", token)
+}
+
+// decodePlistJSONObject decodes a JSON object after its opening brace.
+// If isLiteral is true, the object is always decoded as a dictionary.
+func decodePlistJSONObject(decoder *json.Decoder, isLiteral bool) (PlistValue, error) {
+	dict := PlistDict{}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		key := token.(string)
+
+		if !isLiteral && len(dict) == 0 && isReservedJSONKey(key) {
+			value, err := decodeReservedJSONValue(decoder, key)
+			if err != nil {
+				return nil, err
+			}
+
+			if decoder.More() {
+				return nil, fmt.Errorf("'%s' must be the only key in its object", key)
+			}
+
+			_, err = decoder.Token()
+			return value, err
+		}
+
+		value, err := decodePlistJSONValue(decoder)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", key, err.Error())
+		}
+
+		dict = append(dict, PlistEntry{Key: key, Value: value})
+	}
+
+	_, err := decoder.Token()
+	return dict, err
+}
+
+func decodeReservedJSONValue(decoder *json.Decoder, key string) (PlistValue, error) {
+	if key == jsonDictKey {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		if token != json.Delim('{') {
+			return nil, fmt.Errorf("%s: expected an object - got %v", key, token)
+		}
+
+		return decodePlistJSONObject(decoder, true)
+	}
+
+	value, err := decodePlistJSONValue(decoder)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", key, err.Error())
+	}
+
+	s, ok := value.(PlistString)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected a string - got a %s", key, value.PlistType())
+	}
+
+	if key == jsonDateKey {
+		date, err := time.Parse(time.RFC3339, string(s))
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid date '%s' - %s", key, s, err.Error())
+		}
+		return PlistDate(date), nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(string(s))
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid base64 data - %s", key, err.Error())
+	}
+
+	return PlistData(data), nil
+}
+
+func isReservedJSONKey(key string) bool {
+	return key == jsonDateKey || key == jsonDataKey || key == jsonDictKey
+}
+
+// formatPlistReal formats a real so that it is not mistaken for an
+// integer.
+func formatPlistReal(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eEn") {
+		s = s + ".0"
+	}
+
+	return s
+}
+
+func jsonString(s string) string {
+	var buffer bytes.Buffer
+
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+
+	return strings.TrimSuffix(buffer.String(), newLine)
+}
@@ -0,0 +1,88 @@
+package launchctlutil
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testEncodingPlist contains every type of property list value, along
+// with strings that look like other types.
+var testEncodingPlist = PlistDict{
+	{Key: "Label", Value: PlistString("com.example")},
+	{Key: "Integer", Value: PlistInteger(-42)},
+	{Key: "Real", Value: PlistReal(1)},
+	{Key: "LargeReal", Value: PlistReal(1e21)},
+	{Key: "Bool", Value: PlistBool(true)},
+	{Key: "Date", Value: PlistDate(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))},
+	{Key: "Data", Value: PlistData("hello\x00")},
+	{Key: "Strings", Value: PlistArray{
+		PlistString("true"),
+		PlistString("12"),
+		PlistString("2020-01-02"),
+		PlistString("multiple\nlines: <&>"),
+		PlistString(""),
+	}},
+	{Key: "EmptyArray", Value: PlistArray{}},
+	{Key: "EmptyDict", Value: PlistDict{}},
+	{Key: "Reserved", Value: PlistDict{
+		{Key: "$date", Value: PlistString("not a date")},
+		{Key: "Other", Value: PlistInteger(1)},
+	}},
+	{Key: "Nested", Value: PlistDict{
+		{Key: "$dict", Value: PlistDict{{Key: "$data", Value: PlistString("x")}}},
+	}},
+}
+
+func TestPlistJSONRoundTrip(t *testing.T) {
+	encoded, err := EncodePlistJSON(testEncodingPlist)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, exp := range []string{`"Real": 1.0`, `"Integer": -42`, `{"$date": "2020-01-02T03:04:05Z"}`,
+		`{"$data": "aGVsbG8A"}`, `"multiple\nlines: <&>"`, `"Reserved": {"$dict": {`} {
+		if !strings.Contains(string(encoded), exp) {
+			t.Fatalf("encoded JSON does not contain '%s' - got:\n%s", exp, encoded)
+		}
+	}
+
+	decoded, err := DecodePlistJSON(encoded)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !PlistValuesEqual(testEncodingPlist, decoded) {
+		t.Fatalf("decoded value does not match - got %v", decoded)
+	}
+
+	reencoded, err := EncodePlistJSON(decoded)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if string(reencoded) != string(encoded) {
+		t.Fatalf("key order was not preserved - got:\n%s", reencoded)
+	}
+}
+
+func TestDecodePlistJSONInvalid(t *testing.T) {
+	for _, data := range []string{
+		`{"Label": null}`,
+		`{"Date": {"$date": "yesterday"}}`,
+		`{"Data": {"$data": 1}}`,
+		`{"Date": {"$date": "2020-01-02T03:04:05Z", "Other": 1}}`,
+		`{} {}`,
+	} {
+		_, err := DecodePlistJSON([]byte(data))
+		if err == nil {
+			t.Fatalf("'%s' should be rejected", data)
+		}
+	}
+
+	_, err := EncodePlistJSON(PlistReal(math.NaN()))
+	if err == nil {
+		t.Fatal("NaN should not be encoded as JSON")
+	}
+}
@@ -0,0 +1,251 @@
+package launchctlutil
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+const testPlist = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>com.testing &amp; more</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>echo</string>
+		<string>hello</string>
+	</array>
+	<key>StartInterval</key>
+	<integer>-30</integer>
+	<key>Ratio</key>
+	<real>0.5</real>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>Created</key>
+	<date>2019-08-15T10:00:00Z</date>
+	<key>Blob</key>
+	<data>
+	aGVs
+	bG8=
+	</data>
+	<key>Empty</key>
+	<dict/>
+</dict>
+</plist>
+`
+
+func TestDecodePlist(t *testing.T) {
+	value, err := DecodePlist([]byte(testPlist))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	dict, ok := value.(PlistDict)
+	if !ok {
+		t.Fatalf("root should be a dict - got %s", value.PlistType())
+	}
+
+	if len(dict) != 8 {
+		t.Fatalf("dict should have 8 entries - got %d", len(dict))
+	}
+
+	label, _ := dict.GetString("Label")
+	if label != "com.testing & more" {
+		t.Fatalf("unexpected label - got '%s'", label)
+	}
+
+	args, ok := dict.GetStrings("ProgramArguments")
+	if !ok || len(args) != 2 || args[1] != "hello" {
+		t.Fatalf("unexpected program arguments - got %v", args)
+	}
+
+	interval, _ := dict.GetInteger("StartInterval")
+	if interval != -30 {
+		t.Fatalf("start interval should be -30 - got %d", interval)
+	}
+
+	ratio, _ := dict.Get("Ratio")
+	if ratio != PlistReal(0.5) {
+		t.Fatalf("ratio should be 0.5 - got %v", ratio)
+	}
+
+	runAtLoad, _ := dict.GetBool("RunAtLoad")
+	if !runAtLoad {
+		t.Fatal("run at load should be true")
+	}
+
+	created, _ := dict.Get("Created")
+	if !time.Time(created.(PlistDate)).Equal(time.Date(2019, 8, 15, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected date - got %v", created)
+	}
+
+	blob, _ := dict.Get("Blob")
+	if !bytes.Equal(blob.(PlistData), []byte("hello")) {
+		t.Fatalf("unexpected data - got %v", blob)
+	}
+
+	empty, _ := dict.Get("Empty")
+	if len(empty.(PlistDict)) != 0 {
+		t.Fatal("empty dict should have no entries")
+	}
+}
+
+func TestDecodePlistBinary(t *testing.T) {
+	_, err := DecodePlist([]byte("bplist00junk"))
+	if err != ErrBinaryPlist {
+		t.Fatalf("expected ErrBinaryPlist - got %v", err)
+	}
+}
+
+func TestDecodePlistMissingValue(t *testing.T) {
+	_, err := DecodePlist([]byte(`<plist version="1.0"><dict><key>Label</key></dict></plist>`))
+	if err == nil {
+		t.Fatal("expected an error for a key without a value")
+	}
+}
+
+func TestDiffPlistDicts(t *testing.T) {
+	old := PlistDict{
+		{Key: "Label", Value: PlistString("com.testing")},
+		{Key: "RunAtLoad", Value: PlistBool(true)},
+		{Key: "ProgramArguments", Value: PlistArray{PlistString("echo"), PlistString("hello")}},
+	}
+
+	new := PlistDict{
+		{Key: "ProgramArguments", Value: PlistArray{PlistString("echo"), PlistString("goodbye")}},
+		{Key: "Label", Value: PlistString("com.testing")},
+		{Key: "StartInterval", Value: PlistInteger(30)},
+	}
+
+	changes := DiffPlistDicts(old, new)
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 changes - got %d", len(changes))
+	}
+
+	if changes[0].Key != "RunAtLoad" || changes[0].New != nil {
+		t.Fatalf("RunAtLoad should be removed - got %+v", changes[0])
+	}
+
+	if changes[1].Key != "ProgramArguments" || changes[1].Old == nil || changes[1].New == nil {
+		t.Fatalf("ProgramArguments should be changed - got %+v", changes[1])
+	}
+
+	if changes[2].Key != "StartInterval" || changes[2].Old != nil {
+		t.Fatalf("StartInterval should be added - got %+v", changes[2])
+	}
+}
+
+func TestPlistValuesEqual(t *testing.T) {
+	a := PlistDict{
+		{Key: "A", Value: PlistData("hello")},
+		{Key: "B", Value: PlistDate(time.Date(2019, 8, 15, 10, 0, 0, 0, time.UTC))},
+	}
+
+	b := PlistDict{
+		{Key: "B", Value: PlistDate(time.Date(2019, 8, 15, 12, 0, 0, 0, time.FixedZone("", 2*60*60)))},
+		{Key: "A", Value: PlistData("hello")},
+	}
+
+	if !PlistValuesEqual(a, b) {
+		t.Fatal("dicts should be equal")
+	}
+
+	if PlistValuesEqual(PlistInteger(1), PlistReal(1)) {
+		t.Fatal("values of different types should not be equal")
+	}
+}
+
+func TestEncodePlistFormatCanonical(t *testing.T) {
+	data := make([]byte, 50)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	value := PlistDict{
+		{Key: "b", Value: PlistReal(0.1)},
+		{Key: "a", Value: PlistData(data)},
+		{Key: "Z", Value: PlistDict{
+			{Key: "data", Value: PlistData(data)},
+			{Key: "empty", Value: PlistData{}},
+		}},
+		{Key: "c", Value: PlistArray{PlistReal(0), PlistReal(2), PlistArray{}, PlistDict{}}},
+		{Key: "d", Value: PlistDate(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))},
+	}
+
+	exp := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Z</key>
+	<dict>
+		<key>data</key>
+		<data>
+		AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8gISIjJCUmJygpKiss
+		LS4vMDE=
+		</data>
+		<key>empty</key>
+		<data>
+		</data>
+	</dict>
+	<key>a</key>
+	<data>
+	AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8gISIjJCUmJygpKissLS4vMDE=
+	</data>
+	<key>b</key>
+	<real>0.10000000000000001</real>
+	<key>c</key>
+	<array>
+		<real>0.0</real>
+		<real>2</real>
+		<array/>
+		<dict/>
+	</array>
+	<key>d</key>
+	<date>2020-01-02T03:04:05Z</date>
+</dict>
+</plist>
+`
+
+	encoded := string(EncodePlistFormat(value, PlistFormat{Canonical: true}))
+	if encoded != exp {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, encoded)
+	}
+
+	decoded, err := DecodePlist([]byte(encoded))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !PlistValuesEqual(value, decoded) {
+		t.Fatalf("decoded value does not match - got %v", decoded)
+	}
+}
+
+func TestEncodePlistFormatSortKeys(t *testing.T) {
+	// U+1F600 sorts after U+FF21 by UTF-8 bytes, but before it by
+	// UTF-16 code units.
+	value := PlistDict{
+		{Key: "\U0001F600", Value: PlistBool(true)},
+		{Key: "Ａ", Value: PlistBool(true)},
+		{Key: "b", Value: PlistBool(true)},
+		{Key: "B", Value: PlistBool(true)},
+	}
+
+	exp := "<dict>\n" +
+		"\t\t<key>B</key>\n\t\t<true/>\n" +
+		"\t\t<key>b</key>\n\t\t<true/>\n" +
+		"\t\t<key>\U0001F600</key>\n\t\t<true/>\n" +
+		"\t\t<key>Ａ</key>\n\t\t<true/>\n" +
+		"\t</dict>\n"
+
+	encoded := string(EncodePlistFormat(value, PlistFormat{Indent: "\t", SortKeys: true}))
+	if !bytes.Contains([]byte(encoded), []byte(exp)) {
+		t.Fatalf("expected:\n%s\ngot:\n%s", exp, encoded)
+	}
+
+	if value[0].Key != "\U0001F600" {
+		t.Fatal("sorting the keys should not modify the value")
+	}
+}
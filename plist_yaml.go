@@ -0,0 +1,218 @@
+package launchctlutil
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	yamlStrTag       = "!!str"
+	yamlIntTag       = "!!int"
+	yamlFloatTag     = "!!float"
+	yamlBoolTag      = "!!bool"
+	yamlTimestampTag = "!!timestamp"
+	yamlBinaryTag    = "!!binary"
+	yamlNullTag      = "!!null"
+)
+
+// EncodePlistYAML returns the YAML representation of a property list value.
+// The representation is lossless; DecodePlistYAML returns the same value.
+//
+// Strings, integers, reals, booleans, arrays, and dictionaries are
+// encoded as their YAML equivalents, and dictionary keys keep their
+// order. Dates are encoded as timestamps (e.g., 2006-01-02T15:04:05Z),
+// and data is encoded as base64 with the !!binary tag. Strings that
+// would otherwise be read as another type are quoted.
+func EncodePlistYAML(value PlistValue) ([]byte, error) {
+	node, err := plistYAMLNode(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+
+	err = encoder.Encode(node)
+	if err != nil {
+		return nil, err
+	}
+
+	err = encoder.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func plistYAMLNode(value PlistValue) (*yaml.Node, error) {
+	scalar := func(tag string, value string) *yaml.Node {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value}
+	}
+
+	switch v := value.(type) {
+	case PlistString:
+		return scalar(yamlStrTag, string(v)), nil
+	case PlistInteger:
+		return scalar(yamlIntTag, strconv.FormatInt(int64(v), 10)), nil
+	case PlistReal:
+		f := float64(v)
+		switch {
+		case math.IsNaN(f):
+			return scalar(yamlFloatTag, ".nan"), nil
+		case math.IsInf(f, 1):
+			return scalar(yamlFloatTag, ".inf"), nil
+		case math.IsInf(f, -1):
+			return scalar(yamlFloatTag, "-.inf"), nil
+		}
+		return scalar(yamlFloatTag, formatPlistReal(f)), nil
+	case PlistBool:
+		return scalar(yamlBoolTag, strconv.FormatBool(bool(v))), nil
+	case PlistDate:
+		return scalar(yamlTimestampTag, time.Time(v).UTC().Format(time.RFC3339)), nil
+	case PlistData:
+		return scalar(yamlBinaryTag, base64.StdEncoding.EncodeToString(v)), nil
+	case PlistArray:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		if len(v) == 0 {
+			node.Style = yaml.FlowStyle
+		}
+		for _, element := range v {
+			child, err := plistYAMLNode(element)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, child)
+		}
+		return node, nil
+	case PlistDict:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		if len(v) == 0 {
+			node.Style = yaml.FlowStyle
+		}
+		for _, entry := range v {
+			child, err := plistYAMLNode(entry.Value)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, scalar(yamlStrTag, entry.Key), child)
+		}
+		return node, nil
+	}
+
+	return nil, fmt.Errorf("unsupported property list value %T", value)
+}
+
+// DecodePlistYAML decodes the YAML representation of a property list
+// value. See EncodePlistYAML for details. Anchors and aliases are
+// supported, but null values are not.
+func DecodePlistYAML(data []byte) (PlistValue, error) {
+	var document yaml.Node
+
+	err := yaml.Unmarshal(data, &document)
+	if err != nil {
+		return nil, err
+	}
+
+	if document.Kind != yaml.DocumentNode || len(document.Content) == 0 {
+		return nil, errors.New("the YAML document is empty")
+	}
+
+	return decodePlistYAMLNode(document.Content[0])
+}
+
+func decodePlistYAMLNode(node *yaml.Node) (PlistValue, error) {
+	switch node.Kind {
+	case yaml.AliasNode:
+		return decodePlistYAMLNode(node.Alias)
+	case yaml.SequenceNode:
+		array := PlistArray{}
+		for _, child := range node.Content {
+			element, err := decodePlistYAMLNode(child)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, element)
+		}
+		return array, nil
+	case yaml.MappingNode:
+		dict := PlistDict{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if key.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("line %d: dictionary keys must be strings", key.Line)
+			}
+
+			if key.ShortTag() == "!!merge" {
+				return nil, fmt.Errorf("line %d: merge keys are not supported", key.Line)
+			}
+
+			value, err := decodePlistYAMLNode(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+
+			dict = append(dict, PlistEntry{Key: key.Value, Value: value})
+		}
+		return dict, nil
+	case yaml.ScalarNode:
+		return decodePlistYAMLScalar(node)
+	}
+
+	return nil, fmt.Errorf("line %d: unsupported YAML node", node.Line)
+}
+
+func decodePlistYAMLScalar(node *yaml.Node) (PlistValue, error) {
+	switch node.ShortTag() {
+	case yamlStrTag:
+		return PlistString(node.Value), nil
+	case yamlIntTag:
+		var i int64
+		err := node.Decode(&i)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid integer '%s'", node.Line, node.Value)
+		}
+		return PlistInteger(i), nil
+	case yamlFloatTag:
+		var f float64
+		err := node.Decode(&f)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid real '%s'", node.Line, node.Value)
+		}
+		return PlistReal(f), nil
+	case yamlBoolTag:
+		var b bool
+		err := node.Decode(&b)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid boolean '%s'", node.Line, node.Value)
+		}
+		return PlistBool(b), nil
+	case yamlTimestampTag:
+		var t time.Time
+		err := node.Decode(&t)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid timestamp '%s'", node.Line, node.Value)
+		}
+		return PlistDate(t), nil
+	case yamlBinaryTag:
+		var s string
+		err := node.Decode(&s)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid binary data - %s", node.Line, err.Error())
+		}
+		return PlistData(s), nil
+	case yamlNullTag:
+		return nil, fmt.Errorf("line %d: null has no property list equivalent", node.Line)
+	}
+
+	return nil, fmt.Errorf("line %d: unsupported YAML tag '%s'", node.Line, node.Tag)
+}
@@ -0,0 +1,93 @@
+package launchctlutil
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestPlistYAMLRoundTrip(t *testing.T) {
+	encoded, err := EncodePlistYAML(testEncodingPlist)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, exp := range []string{"Real: 1.0\n", "Integer: -42\n", "Date: 2020-01-02T03:04:05Z\n",
+		"Data: !!binary aGVsbG8A\n", `- "true"`, `- "12"`, `- "2020-01-02"`} {
+		if !strings.Contains(string(encoded), exp) {
+			t.Fatalf("encoded YAML does not contain '%s' - got:\n%s", exp, encoded)
+		}
+	}
+
+	decoded, err := DecodePlistYAML(encoded)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !PlistValuesEqual(testEncodingPlist, decoded) {
+		t.Fatalf("decoded value does not match - got %v", decoded)
+	}
+
+	reencoded, err := EncodePlistYAML(decoded)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if string(reencoded) != string(encoded) {
+		t.Fatalf("key order was not preserved - got:\n%s", reencoded)
+	}
+
+	special, err := EncodePlistYAML(PlistArray{PlistReal(math.Inf(-1)), PlistReal(math.NaN())})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	decoded, err = DecodePlistYAML(special)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	reals := decoded.(PlistArray)
+	if !math.IsInf(float64(reals[0].(PlistReal)), -1) || !math.IsNaN(float64(reals[1].(PlistReal))) {
+		t.Fatalf("non-finite reals were not preserved - got %v", reals)
+	}
+}
+
+func TestDecodePlistYAMLHandWritten(t *testing.T) {
+	data := `
+defaults: &defaults
+  RunAtLoad: yes
+Label: com.example
+ProgramArguments: [/bin/echo, hello]
+Nice: 5
+Environment: *defaults
+`
+
+	value, err := DecodePlistYAML([]byte(data))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	dict := value.(PlistDict)
+
+	nice, _ := dict.GetInteger("Nice")
+	if nice != 5 {
+		t.Fatalf("Nice should be 5 - got %d", nice)
+	}
+
+	args, _ := dict.GetStrings("ProgramArguments")
+	if strings.Join(args, " ") != "/bin/echo hello" {
+		t.Fatalf("unexpected program arguments - got %q", args)
+	}
+
+	environment, _ := dict.Get("Environment")
+	runAtLoad, ok := environment.(PlistDict).Get("RunAtLoad")
+	if !ok || runAtLoad != PlistString("yes") {
+		t.Fatalf("YAML 1.1 booleans should be strings - got %v", runAtLoad)
+	}
+
+	_, err = DecodePlistYAML([]byte("Label: ~\n"))
+	if err == nil {
+		t.Fatal("null values should be rejected")
+	}
+}
@@ -0,0 +1,165 @@
+package launchctlutil
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+)
+
+const (
+	// RootPrivilege is the privilege of running with an effective
+	// UID of 0.
+	RootPrivilege Privilege = "root"
+
+	systemDomain = "system"
+	guiDomain    = "gui/"
+	anyUser      = "*"
+)
+
+// Privilege is a privilege required by an operation.
+type Privilege string
+
+// PrivilegeError is returned when the current process lacks the
+// privilege required by an operation.
+type PrivilegeError struct {
+	// Operation is the operation that was attempted (e.g., "install").
+	Operation string
+
+	// Domain is the launchd domain the operation targeted (e.g.,
+	// "system" or "gui/501"). Global agents are reported as "gui/*"
+	// because they are loaded into every user's domain.
+	Domain string
+
+	// Required is the privilege the operation needed.
+	Required Privilege
+}
+
+func (o *PrivilegeError) Error() string {
+	return fmt.Sprintf("%s in the %s domain requires %s privileges", o.Operation, o.Domain, o.Required)
+}
+
+// ReadOnlyKindError is returned when an operation would modify a service
+// of a Kind that is provided by the operating system.
+type ReadOnlyKindError struct {
+	// Operation is the operation that was attempted (e.g., "install").
+	Operation string
+
+	// Kind is the read-only Kind.
+	Kind Kind
+}
+
+func (o *ReadOnlyKindError) Error() string {
+	return fmt.Sprintf("%s of %s configurations is not allowed because they are provided by the operating system",
+		o.Operation, o.Kind)
+}
+
+// PrivilegeReport describes the privileges of the current process.
+type PrivilegeReport struct {
+	UID  int
+	EUID int
+	GID  int
+	EGID int
+
+	// Username is the name of the user with the effective UID. It is
+	// empty if the user could not be looked up.
+	Username string
+
+	// IsRoot is true if the effective UID is 0.
+	IsRoot bool
+
+	// UserDomain is the launchd domain of the effective user's
+	// agents (e.g., "gui/501").
+	UserDomain string
+
+	// CanManageUserAgents is true if the current user's agents
+	// can be managed.
+	CanManageUserAgents bool
+
+	// CanManageGlobalAgents is true if GlobalAgent configurations
+	// can be installed and removed.
+	CanManageGlobalAgents bool
+
+	// CanManageDaemons is true if services in the system domain
+	// can be managed.
+	CanManageDaemons bool
+}
+
+// Privileges reports the privileges of the current process based on
+// its effective UID.
+func Privileges() PrivilegeReport {
+	report := PrivilegeReport{
+		UID:        os.Getuid(),
+		EUID:       os.Geteuid(),
+		GID:        os.Getgid(),
+		EGID:       os.Getegid(),
+		UserDomain: guiDomain + strconv.Itoa(os.Geteuid()),
+	}
+
+	u, err := user.LookupId(strconv.Itoa(report.EUID))
+	if err == nil {
+		report.Username = u.Username
+	}
+
+	report.IsRoot = report.EUID == 0
+	report.CanManageUserAgents = len(os.Getenv("HOME")) > 0
+	report.CanManageGlobalAgents = report.IsRoot
+	report.CanManageDaemons = report.IsRoot
+
+	return report
+}
+
+// domainForKind returns the launchd domain that services of the
+// specified Kind are loaded into for the specified effective UID.
+func domainForKind(kind Kind, euid int) string {
+	switch {
+	case kind.isSystemDomain():
+		return systemDomain
+	case kind == GlobalAgent:
+		return guiDomain + anyUser
+	default:
+		return guiDomain + strconv.Itoa(euid)
+	}
+}
+
+// checkPrivileges returns the Runner to use for performing the operation
+// on services of the specified Kind. It returns a *PrivilegeError if the
+// current process lacks the required privileges and PrivilegedRunner
+// is not set.
+func checkPrivileges(operation string, kind Kind) (Runner, error) {
+	return requirePrivileges(operation, kind, kind.isSystemDomain(), os.Geteuid())
+}
+
+// checkInstallable is like checkPrivileges, but for operations that
+// install or remove configuration files. It returns a *ReadOnlyKindError
+// if services of the specified Kind cannot be modified.
+func checkInstallable(operation string, kind Kind) (Runner, error) {
+	if kind.isReadOnly() {
+		return nil, &ReadOnlyKindError{
+			Operation: operation,
+			Kind:      kind,
+		}
+	}
+
+	return requirePrivileges(operation, kind, kind.requiresRootToInstall(), os.Geteuid())
+}
+
+func requirePrivileges(operation string, kind Kind, requiresRoot bool, euid int) (Runner, error) {
+	return requirePrivilegesInDomain(operation, domainForKind(kind, euid), requiresRoot, euid)
+}
+
+func requirePrivilegesInDomain(operation string, domain string, requiresRoot bool, euid int) (Runner, error) {
+	if !requiresRoot || euid == 0 {
+		return localRunner{}, nil
+	}
+
+	if PrivilegedRunner != nil {
+		return PrivilegedRunner, nil
+	}
+
+	return nil, &PrivilegeError{
+		Operation: operation,
+		Domain:    domain,
+		Required:  RootPrivilege,
+	}
+}
@@ -0,0 +1,93 @@
+package launchctlutil
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPrivileges(t *testing.T) {
+	report := Privileges()
+
+	if report.EUID != os.Geteuid() {
+		t.Fatalf("EUID should be %d - got %d", os.Geteuid(), report.EUID)
+	}
+
+	if report.IsRoot != (os.Geteuid() == 0) {
+		t.Fatalf("is root should be %t - got %t", os.Geteuid() == 0, report.IsRoot)
+	}
+
+	if report.CanManageDaemons != report.IsRoot {
+		t.Fatal("only root should be able to manage daemons")
+	}
+}
+
+func TestRequirePrivileges(t *testing.T) {
+	_, err := requirePrivileges("start", Daemon, true, 501)
+	if err == nil {
+		t.Fatal("expected an error when not root")
+	}
+
+	privErr, ok := err.(*PrivilegeError)
+	if !ok {
+		t.Fatalf("expected a *PrivilegeError - got %T", err)
+	}
+
+	if privErr.Operation != "start" || privErr.Domain != "system" || privErr.Required != RootPrivilege {
+		t.Fatalf("unexpected privilege error - got %+v", privErr)
+	}
+
+	_, err = requirePrivileges("install", GlobalAgent, true, 501)
+	if err == nil || err.(*PrivilegeError).Domain != "gui/*" {
+		t.Fatalf("expected a gui/* privilege error - got %v", err)
+	}
+
+	_, err = requirePrivileges("start", Daemon, true, 0)
+	if err != nil {
+		t.Fatalf("root should not get an error - got %s", err.Error())
+	}
+
+	_, err = requirePrivileges("start", UserAgent, false, 501)
+	if err != nil {
+		t.Fatalf("user agents should not require root - got %s", err.Error())
+	}
+}
+
+func TestRequirePrivilegesEscalation(t *testing.T) {
+	PrivilegedRunner = &EscalatingRunner{}
+	defer func() {
+		PrivilegedRunner = nil
+	}()
+
+	runner, err := requirePrivileges("start", Daemon, true, 501)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if runner != PrivilegedRunner {
+		t.Fatal("expected the privileged runner to be used")
+	}
+
+	runner, err = requirePrivileges("start", UserAgent, false, 501)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if runner == PrivilegedRunner {
+		t.Fatal("the privileged runner should not be used for user agents")
+	}
+}
+
+func TestCheckInstallableReadOnly(t *testing.T) {
+	for _, kind := range []Kind{SystemAgent, SystemDaemon} {
+		_, err := checkInstallable("install", kind)
+
+		readOnlyErr, ok := err.(*ReadOnlyKindError)
+		if !ok {
+			t.Fatalf("expected a *ReadOnlyKindError for %s - got %T", kind, err)
+		}
+
+		if readOnlyErr.Operation != "install" || readOnlyErr.Kind != kind {
+			t.Fatalf("unexpected read-only kind error - got %+v", readOnlyErr)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package launchctlutil
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+const (
+	CreateAction ActionType = "create"
+	UpdateAction ActionType = "update"
+	DeleteAction ActionType = "delete"
+)
+
+// ActionType is the kind of change that a PlanAction makes.
+type ActionType string
+
+// PlanScope describes the installed services that a desired set of
+// Configurations owns.
+type PlanScope struct {
+	// LabelPrefix is the label prefix that the owned services share
+	// (e.g., "com.example."). It is required. Installed services with
+	// the prefix that are not desired are deleted.
+	LabelPrefix string
+
+	// Kinds are the kinds of services that are searched for services
+	// to delete. The kinds of the desired Configurations are always
+	// searched.
+	Kinds []Kind
+}
+
+// PlanAction is a change that Apply makes to an installed service.
+type PlanAction struct {
+	Type     ActionType
+	Label    string
+	Kind     Kind
+	FilePath string
+
+	// Configuration is the desired Configuration. It is nil for
+	// DeleteAction.
+	Configuration Configuration
+
+	// Changes are the top-level keys that differ between the
+	// installed and desired configuration files. It is only set
+	// for UpdateAction, and is empty if the installed file could
+	// not be parsed.
+	Changes []PlistChange
+}
+
+// ReconcilePlan is the set of actions that make the installed services
+// match a desired set of Configurations.
+type ReconcilePlan struct {
+	// Actions are ordered so that deletions happen first.
+	Actions []PlanAction
+
+	// Unchanged are the labels of the desired Configurations that are
+	// already installed.
+	Unchanged []string
+}
+
+// HasChanges returns true if the plan contains any actions.
+func (o ReconcilePlan) HasChanges() bool {
+	return len(o.Actions) > 0
+}
+
+// ActionResult is the outcome of applying a PlanAction. Err is nil if
+// the action succeeded.
+type ActionResult struct {
+	Action PlanAction
+	Err    error
+}
+
+// Plan compares the desired Configurations to the services installed on
+// disk under the scope's label prefix. A desired Configuration is created
+// if its file does not exist, and updated if the file's contents differ.
+// Installed services in scope that are not desired are deleted.
+//
+// The read-only SystemAgent and SystemDaemon kinds, and the BundledAgent
+// and BundledDaemon kinds, are not supported.
+func Plan(desired []Configuration, scope PlanScope) (ReconcilePlan, error) {
+	if len(scope.LabelPrefix) == 0 {
+		return ReconcilePlan{}, errors.New("a label prefix is required")
+	}
+
+	kinds := append([]Kind{}, scope.Kinds...)
+	searched := make(map[Kind]bool)
+	for _, kind := range kinds {
+		searched[kind] = true
+	}
+
+	for _, config := range desired {
+		if !searched[config.GetKind()] {
+			searched[config.GetKind()] = true
+			kinds = append(kinds, config.GetKind())
+		}
+	}
+
+	for _, kind := range kinds {
+		if kind.isReadOnly() || kind == BundledAgent || kind == BundledDaemon {
+			return ReconcilePlan{}, fmt.Errorf("%s services cannot be reconciled", kind)
+		}
+	}
+
+	var installed []DiscoveredService
+	if len(kinds) > 0 {
+		var err error
+		installed, err = Discover(DiscoverOptions{
+			Kinds:           kinds,
+			SkipLoadedCheck: true,
+		})
+		if err != nil {
+			return ReconcilePlan{}, err
+		}
+	}
+
+	return planReconcile(desired, installed, scope.LabelPrefix, ioutil.ReadFile)
+}
+
+func planReconcile(desired []Configuration, installed []DiscoveredService, labelPrefix string,
+	readFile func(string) ([]byte, error)) (ReconcilePlan, error) {
+	var plan ReconcilePlan
+
+	labels := make(map[string]bool)
+	pathsToConfigs := make(map[string]Configuration)
+	var desiredPaths []string
+
+	for _, config := range desired {
+		label := config.GetLabel()
+		if !strings.HasPrefix(label, labelPrefix) {
+			return ReconcilePlan{}, fmt.Errorf("label '%s' does not start with '%s'", label, labelPrefix)
+		}
+
+		if labels[label] {
+			return ReconcilePlan{}, fmt.Errorf("label '%s' is desired more than once", label)
+		}
+		labels[label] = true
+
+		filePath, err := config.GetFilePath()
+		if err != nil {
+			return ReconcilePlan{}, err
+		}
+
+		pathsToConfigs[filePath] = config
+		desiredPaths = append(desiredPaths, filePath)
+	}
+
+	installedPaths := make(map[string]DiscoveredService)
+
+	for _, service := range installed {
+		_, isDesired := pathsToConfigs[service.FilePath]
+		if !isDesired && !inReconcileScope(service, labelPrefix) {
+			continue
+		}
+
+		installedPaths[service.FilePath] = service
+
+		if isDesired {
+			continue
+		}
+
+		label := service.Label
+		if len(label) == 0 {
+			label = strings.TrimSuffix(path.Base(service.FilePath), plistFileSuffix)
+		}
+
+		plan.Actions = append(plan.Actions, PlanAction{
+			Type:     DeleteAction,
+			Label:    label,
+			Kind:     service.Kind,
+			FilePath: service.FilePath,
+		})
+	}
+
+	for _, filePath := range desiredPaths {
+		config := pathsToConfigs[filePath]
+
+		action := PlanAction{
+			Type:          CreateAction,
+			Label:         config.GetLabel(),
+			Kind:          config.GetKind(),
+			FilePath:      filePath,
+			Configuration: config,
+		}
+
+		service, isInstalled := installedPaths[filePath]
+		if isInstalled {
+			contents, err := readFile(filePath)
+			if err != nil {
+				return ReconcilePlan{}, fmt.Errorf("failed to read installed configuration '%s' - %s",
+					filePath, err.Error())
+			}
+
+			if string(contents) == config.GetContents() {
+				plan.Unchanged = append(plan.Unchanged, config.GetLabel())
+				continue
+			}
+
+			action.Type = UpdateAction
+			action.Changes = planChanges(service, contents, config)
+		}
+
+		plan.Actions = append(plan.Actions, action)
+	}
+
+	return plan, nil
+}
+
+// inReconcileScope returns true if the service's label starts with the
+// prefix. The file name is used for files without a label.
+func inReconcileScope(service DiscoveredService, labelPrefix string) bool {
+	if len(service.Label) > 0 {
+		return strings.HasPrefix(service.Label, labelPrefix)
+	}
+
+	return strings.HasPrefix(path.Base(service.FilePath), labelPrefix)
+}
+
+func planChanges(service DiscoveredService, contents []byte, config Configuration) []PlistChange {
+	if service.ParseErr != nil {
+		return nil
+	}
+
+	old, err := decodeConfigurationPlist(contents)
+	if err != nil {
+		return nil
+	}
+
+	updated, err := ConfigurationPlist(config)
+	if err != nil {
+		return nil
+	}
+
+	return DiffPlistDicts(old, updated)
+}
+
+// Apply executes the plan's actions in order and returns the result of
+// each one. Created and updated services are installed with Install, and
+// deleted services are removed with Remove. An action that fails does not
+// prevent the remaining actions from being applied.
+func Apply(plan ReconcilePlan) []ActionResult {
+	results := make([]ActionResult, len(plan.Actions))
+
+	for i, action := range plan.Actions {
+		results[i].Action = action
+
+		switch action.Type {
+		case CreateAction, UpdateAction:
+			if action.Configuration == nil {
+				results[i].Err = fmt.Errorf("%s action for '%s' has no configuration", action.Type, action.Label)
+				continue
+			}
+
+			results[i].Err = Install(action.Configuration)
+		case DeleteAction:
+			results[i].Err = Remove(action.FilePath, action.Kind)
+		default:
+			results[i].Err = fmt.Errorf("unknown action type '%s'", action.Type)
+		}
+	}
+
+	return results
+}
@@ -0,0 +1,108 @@
+package launchctlutil
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func testReconcileConfiguration(t *testing.T, label string, command string) Configuration {
+	config, err := NewConfigurationBuilder().
+		SetKind(Daemon).
+		SetLabel(label).
+		SetCommand(command).
+		Build()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	return config
+}
+
+func TestPlanReconcile(t *testing.T) {
+	unchanged := testReconcileConfiguration(t, "com.example.unchanged", "/bin/true")
+	updated := testReconcileConfiguration(t, "com.example.updated", "/bin/true")
+	created := testReconcileConfiguration(t, "com.example.created", "/bin/true")
+
+	installed := []DiscoveredService{
+		{Label: "com.example.unchanged", Kind: Daemon, FilePath: "/Library/LaunchDaemons/com.example.unchanged.plist"},
+		{Label: "com.example.updated", Kind: Daemon, FilePath: "/Library/LaunchDaemons/com.example.updated.plist"},
+		{Label: "com.example.old", Kind: Daemon, FilePath: "/Library/LaunchDaemons/com.example.old.plist"},
+		{Kind: Daemon, FilePath: "/Library/LaunchDaemons/com.example.broken.plist", ParseErr: errors.New("bad")},
+		{Label: "com.other", Kind: Daemon, FilePath: "/Library/LaunchDaemons/com.other.plist"},
+	}
+
+	outdated := testReconcileConfiguration(t, "com.example.updated", "/bin/false")
+
+	files := map[string]string{
+		"/Library/LaunchDaemons/com.example.unchanged.plist": unchanged.GetContents(),
+		"/Library/LaunchDaemons/com.example.updated.plist":   outdated.GetContents(),
+	}
+
+	readFile := func(filePath string) ([]byte, error) {
+		contents, ok := files[filePath]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return []byte(contents), nil
+	}
+
+	plan, err := planReconcile([]Configuration{unchanged, updated, created}, installed, "com.example.", readFile)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	exp := []struct {
+		actionType ActionType
+		label      string
+	}{
+		{DeleteAction, "com.example.old"},
+		{DeleteAction, "com.example.broken"},
+		{UpdateAction, "com.example.updated"},
+		{CreateAction, "com.example.created"},
+	}
+
+	if len(plan.Actions) != len(exp) {
+		t.Fatalf("expected %d actions - got %+v", len(exp), plan.Actions)
+	}
+
+	for i, action := range plan.Actions {
+		if action.Type != exp[i].actionType || action.Label != exp[i].label {
+			t.Fatalf("action %d should %s '%s' - got %s '%s'",
+				i, exp[i].actionType, exp[i].label, action.Type, action.Label)
+		}
+	}
+
+	changes := plan.Actions[2].Changes
+	if len(changes) != 1 || changes[0].Key != "ProgramArguments" {
+		t.Fatalf("unexpected changes - got %+v", changes)
+	}
+
+	if len(plan.Unchanged) != 1 || plan.Unchanged[0] != "com.example.unchanged" {
+		t.Fatalf("unexpected unchanged labels - got %v", plan.Unchanged)
+	}
+}
+
+func TestPlanReconcileInvalid(t *testing.T) {
+	config := testReconcileConfiguration(t, "com.example.job", "/bin/true")
+
+	_, err := planReconcile([]Configuration{config}, nil, "org.example.", nil)
+	if err == nil {
+		t.Fatal("a label without the prefix should be rejected")
+	}
+
+	_, err = planReconcile([]Configuration{config, config}, nil, "com.example.", nil)
+	if err == nil {
+		t.Fatal("duplicate labels should be rejected")
+	}
+
+	_, err = Plan(nil, PlanScope{})
+	if err == nil {
+		t.Fatal("an empty label prefix should be rejected")
+	}
+
+	_, err = Plan(nil, PlanScope{LabelPrefix: "com.example.", Kinds: []Kind{SystemDaemon}})
+	if err == nil {
+		t.Fatal("read-only kinds should be rejected")
+	}
+}
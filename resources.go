@@ -0,0 +1,106 @@
+package launchctlutil
+
+import (
+	"fmt"
+)
+
+const (
+	// ResourceCPU is the maximum amount of CPU time in seconds.
+	ResourceCPU Resource = "CPU"
+
+	// ResourceCore is the maximum size of a core file in bytes.
+	ResourceCore Resource = "Core"
+
+	// ResourceData is the maximum size of the data segment in bytes.
+	ResourceData Resource = "Data"
+
+	// ResourceFileSize is the maximum size of a file in bytes.
+	ResourceFileSize Resource = "FileSize"
+
+	// ResourceMemoryLock is the maximum amount of memory in bytes
+	// that may be locked with mlock(2).
+	ResourceMemoryLock Resource = "MemoryLock"
+
+	// ResourceNumberOfFiles is the maximum number of open files.
+	ResourceNumberOfFiles Resource = "NumberOfFiles"
+
+	// ResourceNumberOfProcesses is the maximum number of processes
+	// for the service's user.
+	ResourceNumberOfProcesses Resource = "NumberOfProcesses"
+
+	// ResourceResidentSetSize is the maximum resident set size
+	// in bytes.
+	ResourceResidentSetSize Resource = "ResidentSetSize"
+
+	// ResourceStack is the maximum size of the stack segment in bytes.
+	ResourceStack Resource = "Stack"
+)
+
+// Resource is a resource that can be limited using the SoftResourceLimits
+// and HardResourceLimits keys.
+type Resource string
+
+// resources is the order in which resource limits are written.
+var resources = []Resource{
+	ResourceCPU,
+	ResourceCore,
+	ResourceData,
+	ResourceFileSize,
+	ResourceMemoryLock,
+	ResourceNumberOfFiles,
+	ResourceNumberOfProcesses,
+	ResourceResidentSetSize,
+	ResourceStack,
+}
+
+func (o Resource) isKnown() bool {
+	for _, r := range resources {
+		if r == o {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateResourceLimits returns a non-nil error if a limit is for an
+// unknown resource, is negative, or if a soft limit exceeds its
+// corresponding hard limit.
+func validateResourceLimits(soft map[Resource]int, hard map[Resource]int) error {
+	for name, limits := range map[string]map[Resource]int{"soft": soft, "hard": hard} {
+		for resource, value := range limits {
+			if !resource.isKnown() {
+				return fmt.Errorf("unknown %s resource limit '%s'", name, resource)
+			}
+
+			if value < 0 {
+				return fmt.Errorf("%s resource limit '%s' cannot be negative", name, resource)
+			}
+		}
+	}
+
+	for resource, softValue := range soft {
+		hardValue, hasHard := hard[resource]
+		if hasHard && softValue > hardValue {
+			return fmt.Errorf("soft resource limit '%s' (%d) exceeds its hard limit (%d)",
+				resource, softValue, hardValue)
+		}
+	}
+
+	return nil
+}
+
+// resourceLimitsDict returns the value of a SoftResourceLimits or
+// HardResourceLimits key.
+func resourceLimitsDict(limits map[Resource]int) PlistDict {
+	dict := PlistDict{}
+
+	for _, resource := range resources {
+		value, ok := limits[resource]
+		if ok {
+			dict = append(dict, PlistEntry{Key: string(resource), Value: PlistInteger(value)})
+		}
+	}
+
+	return dict
+}
@@ -0,0 +1,270 @@
+package launchctlutil
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// Sudo escalates privileges using sudo.
+	Sudo EscalationMethod = iota
+
+	// OSAScript escalates privileges using osascript's "do shell
+	// script ... with administrator privileges", which prompts the
+	// user for an administrator's credentials.
+	OSAScript EscalationMethod = iota
+)
+
+const (
+	defaultSudo      = "sudo"
+	defaultOSAScript = "osascript"
+	installExePath   = "/usr/bin/install"
+	catExePath       = "/bin/cat"
+	rmExePath        = "/bin/rm"
+	shExePath        = "/bin/sh"
+	sudoExePath      = "/usr/bin/sudo"
+
+	// redirectStdinScript runs its arguments after the first with
+	// stdin read from the file named by the first argument.
+	redirectStdinScript = `f="$1"; shift; exec "$@" < "$f"`
+)
+
+var (
+	// SudoPath is the path to the sudo CLI application.
+	SudoPath = defaultSudo
+
+	// OSAScriptPath is the path to the osascript CLI application.
+	OSAScriptPath = defaultOSAScript
+
+	// PrivilegedRunner is used for operations that require root
+	// privileges when the current process is not root. Privilege
+	// escalation is disabled when it is nil, which is the default.
+	//
+	// Example:
+	//	launchctlutil.PrivilegedRunner = &launchctlutil.EscalatingRunner{
+	//		Method: launchctlutil.Sudo,
+	//	}
+	PrivilegedRunner Runner
+)
+
+// Runner executes launchctl and performs the file operations needed
+// to manage launchd services.
+type Runner interface {
+	// Launchctl runs launchctl with the specified arguments and
+	// returns its combined output.
+	Launchctl(args ...string) (output string, err error)
+
+	// ReadFile returns the contents of the specified file.
+	ReadFile(filePath string) ([]byte, error)
+
+	// WriteFile writes data to the specified file, creating it with
+	// the specified permissions if it does not exist.
+	WriteFile(filePath string, data []byte, perm os.FileMode) error
+
+	// RemoveFile removes the specified file.
+	RemoveFile(filePath string) error
+
+	// ExecuteAsUser runs a command as the user with the specified UID
+	// and GID, passing stdin to it, and returns its combined output.
+	// It is used for file operations in directories that the user
+	// controls, where operations performed as root could be redirected
+	// by the user's symbolic links.
+	ExecuteAsUser(uid int, gid int, stdin []byte, args ...string) (output string, err error)
+}
+
+type localRunner struct{}
+
+func (o localRunner) Launchctl(args ...string) (string, error) {
+	raw, err := exec.Command(ExePath, args...).CombinedOutput()
+	return string(raw), err
+}
+
+func (o localRunner) ReadFile(filePath string) ([]byte, error) {
+	return ioutil.ReadFile(filePath)
+}
+
+func (o localRunner) WriteFile(filePath string, data []byte, perm os.FileMode) error {
+	return ioutil.WriteFile(filePath, data, perm)
+}
+
+func (o localRunner) RemoveFile(filePath string) error {
+	return os.Remove(filePath)
+}
+
+func (o localRunner) ExecuteAsUser(uid int, gid int, stdin []byte, args ...string) (string, error) {
+	command := exec.Command(args[0], args[1:]...)
+	command.Stdin = bytes.NewReader(stdin)
+	command.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{
+			Uid: uint32(uid),
+			Gid: uint32(gid),
+		},
+	}
+
+	raw, err := command.CombinedOutput()
+	return string(raw), err
+}
+
+// EscalationMethod is the mechanism used to gain root privileges.
+type EscalationMethod int
+
+func (o EscalationMethod) String() string {
+	switch o {
+	case Sudo:
+		return "sudo"
+	case OSAScript:
+		return "osascript"
+	default:
+		return "EscalationMethod(" + strconv.Itoa(int(o)) + ")"
+	}
+}
+
+// AuditEntry records a command executed by an EscalatingRunner.
+type AuditEntry struct {
+	Time   time.Time
+	Method EscalationMethod
+	Args   []string
+	Err    error
+}
+
+// EscalatingRunner is a Runner that executes launchctl and file
+// operations as root.
+type EscalatingRunner struct {
+	// Method is the mechanism used to gain root privileges.
+	Method EscalationMethod
+
+	// PasswordFunc provides the current user's password to sudo.
+	// sudo is run non-interactively when it is nil, which requires
+	// cached credentials or a NOPASSWD sudoers rule. It is not used
+	// by the OSAScript method.
+	PasswordFunc func() (string, error)
+
+	// AuditFunc, if non-nil, is called after each escalated command.
+	AuditFunc func(AuditEntry)
+}
+
+func (o *EscalatingRunner) Launchctl(args ...string) (string, error) {
+	return o.execute(append([]string{ExePath}, args...))
+}
+
+func (o *EscalatingRunner) ReadFile(filePath string) ([]byte, error) {
+	output, err := o.execute([]string{catExePath, filePath})
+	if err != nil {
+		return nil, fmt.Errorf("%s - output: %s", err.Error(), output)
+	}
+
+	return []byte(output), nil
+}
+
+func (o *EscalatingRunner) WriteFile(filePath string, data []byte, perm os.FileMode) error {
+	temp, err := ioutil.TempFile("", "launchctlutil-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	_, err = temp.Write(data)
+	temp.Close()
+	if err != nil {
+		return err
+	}
+
+	output, err := o.execute([]string{installExePath, "-m", strconv.FormatUint(uint64(perm.Perm()), 8),
+		"-o", "root", "-g", "wheel", temp.Name(), filePath})
+	if err != nil {
+		return fmt.Errorf("%s - output: %s", err.Error(), output)
+	}
+
+	return nil
+}
+
+func (o *EscalatingRunner) RemoveFile(filePath string) error {
+	output, err := o.execute([]string{rmExePath, filePath})
+	if err != nil {
+		return fmt.Errorf("%s - output: %s", err.Error(), output)
+	}
+
+	return nil
+}
+
+func (o *EscalatingRunner) ExecuteAsUser(uid int, gid int, stdin []byte, args ...string) (string, error) {
+	// The escalated command cannot read the current process's stdin,
+	// so stdin is passed using a temporary file that root reads.
+	temp, err := ioutil.TempFile("", "launchctlutil-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(temp.Name())
+
+	_, err = temp.Write(stdin)
+	temp.Close()
+	if err != nil {
+		return "", err
+	}
+
+	return o.execute(append([]string{shExePath, "-c", redirectStdinScript, shExePath, temp.Name(),
+		sudoExePath, "-n", "-u", "#" + strconv.Itoa(uid), "-g", "#" + strconv.Itoa(gid), "--"}, args...))
+}
+
+func (o *EscalatingRunner) execute(args []string) (string, error) {
+	output, err := o.executeWithMethod(args)
+
+	if o.AuditFunc != nil {
+		o.AuditFunc(AuditEntry{
+			Time:   time.Now(),
+			Method: o.Method,
+			Args:   args,
+			Err:    err,
+		})
+	}
+
+	return output, err
+}
+
+func (o *EscalatingRunner) executeWithMethod(args []string) (string, error) {
+	var command *exec.Cmd
+
+	switch o.Method {
+	case Sudo:
+		if o.PasswordFunc == nil {
+			command = exec.Command(SudoPath, append([]string{"-n", "--"}, args...)...)
+		} else {
+			password, err := o.PasswordFunc()
+			if err != nil {
+				return "", fmt.Errorf("failed to get password for sudo - %s", err.Error())
+			}
+			command = exec.Command(SudoPath, append([]string{"-S", "-p", "", "--"}, args...)...)
+			command.Stdin = strings.NewReader(password + "\n")
+		}
+	case OSAScript:
+		command = exec.Command(OSAScriptPath, "-e", osascriptAdminCommand(args))
+	default:
+		return "", fmt.Errorf("unknown privilege escalation method: %s", o.Method)
+	}
+
+	raw, err := command.CombinedOutput()
+
+	return string(raw), err
+}
+
+// osascriptAdminCommand returns an AppleScript statement that runs
+// the specified command with administrator privileges.
+func osascriptAdminCommand(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+	}
+
+	script := strings.Join(quoted, " ")
+	script = strings.Replace(script, `\`, `\\`, -1)
+	script = strings.Replace(script, `"`, `\"`, -1)
+
+	return `do shell script "` + script + `" with administrator privileges`
+}
@@ -0,0 +1,139 @@
+package launchctlutil
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestOSAScriptAdminCommand(t *testing.T) {
+	script := osascriptAdminCommand([]string{"/bin/rm", `/tmp/it's "quoted"`})
+
+	exp := `do shell script "'/bin/rm' '/tmp/it'\\''s \"quoted\"'" with administrator privileges`
+	if script != exp {
+		t.Fatalf("script should be\n%s\ngot\n%s", exp, script)
+	}
+}
+
+func TestEscalatingRunnerAudit(t *testing.T) {
+	var entries []AuditEntry
+
+	runner := &EscalatingRunner{
+		Method: EscalationMethod(-1),
+		AuditFunc: func(entry AuditEntry) {
+			entries = append(entries, entry)
+		},
+	}
+
+	_, err := runner.Launchctl("list")
+	if err == nil {
+		t.Fatal("expected an error for an unknown escalation method")
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 audit entry - got %d", len(entries))
+	}
+
+	if entries[0].Err == nil || entries[0].Args[0] != ExePath || entries[0].Args[1] != "list" {
+		t.Fatalf("unexpected audit entry - got %+v", entries[0])
+	}
+}
+
+func TestEscalatingRunnerExecuteAsUser(t *testing.T) {
+	var args []string
+
+	runner := &EscalatingRunner{
+		Method: EscalationMethod(-1),
+		AuditFunc: func(entry AuditEntry) {
+			args = entry.Args
+		},
+	}
+
+	runner.ExecuteAsUser(501, 20, []byte("data"), rmExePath, "-f", "/Users/stephen/file")
+
+	exp := []string{sudoExePath, "-n", "-u", "#501", "-g", "#20", "--", rmExePath, "-f", "/Users/stephen/file"}
+	if len(args) != len(exp)+5 || strings.Join(args[5:], " ") != strings.Join(exp, " ") {
+		t.Fatalf("the command should be run with sudo as the user - got %q", args)
+	}
+}
+
+// testRunner is a Runner that keeps track of loaded labels in memory
+// rather than running launchctl. File operations use the real file
+// system.
+type testRunner struct {
+	loaded       map[string]bool
+	unloadErr    error
+	ignoreUnload bool
+	ignoreLoad   bool
+	commands     []string
+}
+
+func (o *testRunner) Launchctl(args ...string) (string, error) {
+	if args[0] == launchctlAsUser {
+		args = args[3:]
+	}
+
+	o.commands = append(o.commands, strings.Join(args, " "))
+
+	switch args[0] {
+	case "list":
+		if len(args) > 1 {
+			if !o.loaded[args[1]] {
+				return "Could not find service \"" + args[1] + "\" in domain for port", errors.New("exit status 113")
+			}
+
+			return "{\n\t\"LastExitStatus\" = 256;\n\t\"Label\" = \"" + args[1] + "\";\n};\n", nil
+		}
+
+		output := "PID\tStatus\tLabel\n"
+		for label := range o.loaded {
+			output += "-\t0\t" + label + "\n"
+		}
+		return output, nil
+	case "unload":
+		if o.unloadErr != nil {
+			return "", o.unloadErr
+		}
+
+		if !o.ignoreUnload {
+			delete(o.loaded, strings.TrimSuffix(path.Base(args[1]), plistFileSuffix))
+		}
+	case "load":
+		if !o.ignoreLoad {
+			o.loaded[strings.TrimSuffix(path.Base(args[1]), plistFileSuffix)] = true
+		}
+	case "remove":
+		delete(o.loaded, args[1])
+	}
+
+	return "", nil
+}
+
+func (o *testRunner) ReadFile(filePath string) ([]byte, error) {
+	return localRunner{}.ReadFile(filePath)
+}
+
+func (o *testRunner) WriteFile(filePath string, data []byte, perm os.FileMode) error {
+	return localRunner{}.WriteFile(filePath, data, perm)
+}
+
+func (o *testRunner) RemoveFile(filePath string) error {
+	return localRunner{}.RemoveFile(filePath)
+}
+
+// ExecuteAsUser runs the command as the current user because tests
+// cannot change users.
+func (o *testRunner) ExecuteAsUser(uid int, gid int, stdin []byte, args ...string) (string, error) {
+	o.commands = append(o.commands, "as "+strconv.Itoa(uid)+": "+path.Base(args[0]))
+
+	command := exec.Command(args[0], args[1:]...)
+	command.Stdin = bytes.NewReader(stdin)
+
+	raw, err := command.CombinedOutput()
+	return string(raw), err
+}
@@ -0,0 +1,98 @@
+package launchctlutil
+
+import (
+	"sort"
+)
+
+const (
+	jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+)
+
+// jsonSchemaTypes maps property list types to JSON Schema types.
+var jsonSchemaTypes = map[string]string{
+	"string":  "string",
+	"integer": "integer",
+	"real":    "number",
+	"bool":    "boolean",
+	"array":   "array",
+	"dict":    "object",
+}
+
+// configurationStringArrayKeys are the launchd keys whose values are
+// arrays of strings.
+var configurationStringArrayKeys = map[string]bool{
+	"ProgramArguments":       true,
+	"WatchPaths":             true,
+	"QueueDirectories":       true,
+	"LimitLoadToSessionType": true,
+	"LimitLoadToHosts":       true,
+	"LimitLoadFromHosts":     true,
+}
+
+// ConfigurationJSONSchema returns a JSON Schema (draft 7) that describes
+// the JSON and YAML representations of a Configuration produced by
+// EncodePlistJSON and EncodePlistYAML. It can be used by editors to
+// validate and complete job definitions.
+//
+// The schema requires the Label key and either the Program or the
+// ProgramArguments key, and describes the types of the keys that are
+// checked by ParseConfiguration. Other keys are allowed.
+func ConfigurationJSONSchema() []byte {
+	var keys []string
+	for key := range configurationKeyTypes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	properties := PlistDict{}
+	for _, key := range keys {
+		properties = append(properties, PlistEntry{Key: key, Value: jsonSchemaProperty(key)})
+	}
+
+	schema := PlistDict{
+		{Key: "$schema", Value: PlistString(jsonSchemaDraft)},
+		{Key: "title", Value: PlistString("launchd job")},
+		{Key: "type", Value: PlistString("object")},
+		{Key: "required", Value: PlistArray{PlistString("Label")}},
+		{Key: "anyOf", Value: PlistArray{
+			PlistDict{{Key: "required", Value: PlistArray{PlistString("Program")}}},
+			PlistDict{{Key: "required", Value: PlistArray{PlistString("ProgramArguments")}}},
+		}},
+		{Key: "properties", Value: properties},
+	}
+
+	// The schema does not contain any dates, data, or non-finite
+	// reals, so it can always be encoded.
+	encoded, _ := EncodePlistJSON(schema)
+
+	return encoded
+}
+
+func jsonSchemaProperty(key string) PlistDict {
+	types := configurationKeyTypes[key]
+
+	property := PlistDict{}
+	if len(types) == 1 {
+		property = append(property, PlistEntry{Key: "type", Value: PlistString(jsonSchemaTypes[types[0]])})
+	} else {
+		var schemaTypes PlistArray
+		for _, t := range types {
+			schemaTypes = append(schemaTypes, PlistString(jsonSchemaTypes[t]))
+		}
+		property = append(property, PlistEntry{Key: "type", Value: schemaTypes})
+	}
+
+	if configurationStringArrayKeys[key] {
+		property = append(property, PlistEntry{Key: "items", Value: PlistDict{
+			{Key: "type", Value: PlistString("string")},
+		}})
+	}
+
+	if key == "EnvironmentVariables" {
+		property = append(property, PlistEntry{Key: "additionalProperties", Value: PlistDict{
+			{Key: "type", Value: PlistString("string")},
+		}})
+	}
+
+	return property
+}
@@ -0,0 +1,37 @@
+package launchctlutil
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestConfigurationJSONSchema(t *testing.T) {
+	var schema struct {
+		Required   []string `json:"required"`
+		Properties map[string]struct {
+			Type interface{} `json:"type"`
+		} `json:"properties"`
+	}
+
+	err := json.Unmarshal(ConfigurationJSONSchema(), &schema)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(schema.Required) != 1 || schema.Required[0] != "Label" {
+		t.Fatalf("Label should be required - got %v", schema.Required)
+	}
+
+	if len(schema.Properties) != len(configurationKeyTypes) {
+		t.Fatalf("expected %d properties - got %d", len(configurationKeyTypes), len(schema.Properties))
+	}
+
+	if schema.Properties["Umask"].Type != "integer" {
+		t.Fatalf("Umask should be an integer - got %v", schema.Properties["Umask"].Type)
+	}
+
+	keepAlive, ok := schema.Properties["KeepAlive"].Type.([]interface{})
+	if !ok || len(keepAlive) != 2 {
+		t.Fatalf("KeepAlive should be a boolean or an object - got %v", schema.Properties["KeepAlive"].Type)
+	}
+}
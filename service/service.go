@@ -0,0 +1,277 @@
+// Package service lets a Go program install and manage itself as a launchd
+// service.
+//
+// A program declares its launchd identity once and passes its command line
+// arguments to Main, which provides the "install", "uninstall", "start",
+// "stop", "status", and "run" subcommands:
+//
+//	func main() {
+//		s, err := service.New(service.Options{
+//			Label: "com.example.worker",
+//			Kind:  launchctlutil.UserAgent,
+//		}, work)
+//		if err != nil {
+//			log.Fatal(err.Error())
+//		}
+//
+//		err = s.Main(os.Args[1:])
+//		if err != nil {
+//			log.Fatal(err.Error())
+//		}
+//	}
+//
+//	func work(ctx context.Context) error {
+//		<-ctx.Done()
+//		return nil
+//	}
+//
+// The installed service runs the program's executable with the "run"
+// subcommand. The context passed to the RunFunc is canceled when launchd
+// sends SIGTERM to stop the service.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/stephen-fox/launchctlutil"
+)
+
+const (
+	// InstallCommand installs and loads the service.
+	InstallCommand = "install"
+
+	// UninstallCommand unloads the service and removes its
+	// configuration file.
+	UninstallCommand = "uninstall"
+
+	// StartCommand starts the installed service.
+	StartCommand = "start"
+
+	// StopCommand stops the installed service.
+	StopCommand = "stop"
+
+	// StatusCommand prints the service's status.
+	StatusCommand = "status"
+
+	// RunCommand runs the service's RunFunc. launchd starts the
+	// service using this command.
+	RunCommand = "run"
+)
+
+// RunFunc does the work of a service. The context is canceled when the
+// process receives SIGTERM or SIGINT. Returning after the context is
+// canceled stops the service gracefully.
+type RunFunc func(ctx context.Context) error
+
+// Options describes a program's launchd identity.
+type Options struct {
+	// Label is the service's label (e.g., "com.example.worker"). It is
+	// required.
+	Label string
+
+	// Kind is the kind of service. It defaults to UserAgent.
+	Kind launchctlutil.Kind
+
+	// Arguments are passed to the executable after the "run"
+	// subcommand when launchd starts the service.
+	Arguments []string
+
+	// KeepAlive, if true, makes launchd restart the service whenever
+	// it exits.
+	KeepAlive bool
+
+	// LogDirectory, if set, is the directory that the service's stdout
+	// and stderr are saved to. See
+	// launchctlutil.ConfigurationBuilder.SetLogParentPath.
+	LogDirectory string
+
+	// Configure, if non-nil, is called before the service's
+	// Configuration is built. It can set keys that Options does
+	// not provide.
+	Configure func(builder launchctlutil.ConfigurationBuilder)
+}
+
+// Service is a program that manages itself as a launchd service.
+type Service struct {
+	options    Options
+	run        RunFunc
+	executable func() (string, error)
+	stdout     io.Writer
+}
+
+// New creates a Service that calls the RunFunc when it is run.
+func New(options Options, run RunFunc) (*Service, error) {
+	if len(options.Label) == 0 {
+		return nil, errors.New("a label is required")
+	}
+
+	if run == nil {
+		return nil, errors.New("a run function is required")
+	}
+
+	return &Service{
+		options:    options,
+		run:        run,
+		executable: os.Executable,
+		stdout:     os.Stdout,
+	}, nil
+}
+
+// Configuration returns the launchd Configuration of the service. It runs
+// the current executable, as reported by os.Executable, with the "run"
+// subcommand.
+func (o *Service) Configuration() (launchctlutil.Configuration, error) {
+	executable, err := o.executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the path to the executable - %s", err.Error())
+	}
+
+	builder := launchctlutil.NewConfigurationBuilder().
+		SetKind(o.options.Kind).
+		SetLabel(o.options.Label).
+		SetCommand(executable).
+		AddArgument(RunCommand).
+		SetRunAtLoad(true)
+
+	for _, argument := range o.options.Arguments {
+		builder.AddArgument(argument)
+	}
+
+	if o.options.KeepAlive {
+		builder.SetKeepAlive(true)
+	}
+
+	if len(o.options.LogDirectory) > 0 {
+		builder.SetLogParentPath(o.options.LogDirectory)
+	}
+
+	if o.options.Configure != nil {
+		o.options.Configure(builder)
+	}
+
+	return builder.Build()
+}
+
+// Install installs and loads the service. An existing installation of
+// the service is replaced.
+func (o *Service) Install() error {
+	config, err := o.Configuration()
+	if err != nil {
+		return err
+	}
+
+	return launchctlutil.Install(config)
+}
+
+// Uninstall unloads the service and removes its configuration file.
+func (o *Service) Uninstall() (launchctlutil.UninstallResult, error) {
+	return launchctlutil.Uninstall(o.options.Label, o.options.Kind)
+}
+
+// Start starts the installed service.
+func (o *Service) Start() error {
+	return launchctlutil.Start(o.options.Label, o.options.Kind)
+}
+
+// Stop stops the installed service.
+func (o *Service) Stop() error {
+	return launchctlutil.Stop(o.options.Label, o.options.Kind)
+}
+
+// Status returns the current status of the service. The status is checked
+// in the launchd domain of the service's Kind, so checking a daemon's
+// status requires root privileges or launchctlutil.PrivilegedRunner.
+func (o *Service) Status() (launchctlutil.StatusDetails, error) {
+	return launchctlutil.CurrentStatusForKind(o.options.Label, o.options.Kind)
+}
+
+// Run calls the service's RunFunc. The RunFunc's context is canceled when
+// the process receives SIGTERM, which launchd sends to stop the service,
+// or SIGINT. A second signal is handled by the Go runtime's default
+// behavior, which terminates the process.
+func (o *Service) Run() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(signals)
+
+	go func() {
+		select {
+		case <-signals:
+			signal.Stop(signals)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	err := o.run(ctx)
+	if err != nil && err == ctx.Err() {
+		// The service was stopped gracefully.
+		return nil
+	}
+
+	return err
+}
+
+// Main runs the subcommand named by the first argument. The arguments
+// should not include the program's name (e.g., os.Args[1:]).
+func (o *Service) Main(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("a command is required - %s", usage())
+	}
+
+	switch args[0] {
+	case InstallCommand:
+		return o.Install()
+	case UninstallCommand:
+		_, err := o.Uninstall()
+		return err
+	case StartCommand:
+		return o.Start()
+	case StopCommand:
+		return o.Stop()
+	case StatusCommand:
+		details, err := o.Status()
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintln(o.stdout, FormatStatus(details))
+		return err
+	case RunCommand:
+		return o.Run()
+	}
+
+	return fmt.Errorf("unknown command '%s' - %s", args[0], usage())
+}
+
+// FormatStatus returns a short description of a service's status (e.g.,
+// "running (pid 123)").
+func FormatStatus(details launchctlutil.StatusDetails) string {
+	switch details.Status {
+	case launchctlutil.Running:
+		if details.GotPid() {
+			return fmt.Sprintf("running (pid %d)", details.Pid)
+		}
+	case launchctlutil.NotRunning:
+		if details.GotLastExitStatus() {
+			return fmt.Sprintf("not running (last exit status %d)", details.LastExitStatus)
+		}
+	}
+
+	return strings.Replace(string(details.Status), "_", " ", -1)
+}
+
+func usage() string {
+	return "expected one of: " + strings.Join([]string{InstallCommand, UninstallCommand,
+		StartCommand, StopCommand, StatusCommand, RunCommand}, ", ")
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stephen-fox/launchctlutil"
+)
+
+func testService(t *testing.T, options Options, run RunFunc) *Service {
+	if run == nil {
+		run = func(ctx context.Context) error {
+			return nil
+		}
+	}
+
+	s, err := New(options, run)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	s.executable = func() (string, error) {
+		return "/usr/local/bin/worker", nil
+	}
+
+	return s
+}
+
+func TestService_Configuration(t *testing.T) {
+	s := testService(t, Options{
+		Label:        "com.testing.worker",
+		Kind:         launchctlutil.Daemon,
+		Arguments:    []string{"-verbose"},
+		KeepAlive:    true,
+		LogDirectory: "/var/log",
+		Configure: func(builder launchctlutil.ConfigurationBuilder) {
+			builder.SetUserName("nobody")
+		},
+	}, nil)
+
+	config, err := s.Configuration()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if config.GetKind() != launchctlutil.Daemon {
+		t.Fatalf("unexpected kind - got %s", config.GetKind())
+	}
+
+	dict, err := launchctlutil.ConfigurationPlist(config)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	args, _ := dict.GetStrings("ProgramArguments")
+	if strings.Join(args, " ") != "/usr/local/bin/worker run -verbose" {
+		t.Fatalf("unexpected program arguments - got %q", args)
+	}
+
+	for _, key := range []string{"RunAtLoad", "KeepAlive"} {
+		value, _ := dict.GetBool(key)
+		if !value {
+			t.Fatalf("%s should be true", key)
+		}
+	}
+
+	userName, _ := dict.GetString("UserName")
+	stdout, _ := dict.GetString("StandardOutPath")
+	if userName != "nobody" || !strings.HasPrefix(stdout, "/var/log/com.testing.worker") {
+		t.Fatalf("unexpected UserName or StandardOutPath - got '%s' and '%s'", userName, stdout)
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	_, err := New(Options{}, func(ctx context.Context) error { return nil })
+	if err == nil {
+		t.Fatal("a service without a label should be rejected")
+	}
+
+	_, err = New(Options{Label: "com.testing"}, nil)
+	if err == nil {
+		t.Fatal("a service without a run function should be rejected")
+	}
+}
+
+func TestService_MainRun(t *testing.T) {
+	expErr := errors.New("failed")
+
+	s := testService(t, Options{Label: "com.testing"}, func(ctx context.Context) error {
+		return expErr
+	})
+
+	err := s.Main([]string{RunCommand})
+	if err != expErr {
+		t.Fatalf("expected the run function's error - got %v", err)
+	}
+
+	for _, args := range [][]string{nil, {"restart"}} {
+		err = s.Main(args)
+		if err == nil || !strings.Contains(err.Error(), "expected one of") {
+			t.Fatalf("%q should be rejected with a usage message - got %v", args, err)
+		}
+	}
+}
+
+func TestService_RunSigterm(t *testing.T) {
+	started := make(chan struct{})
+
+	s := testService(t, Options{Label: "com.testing"}, func(ctx context.Context) error {
+		close(started)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			return errors.New("the context was not canceled")
+		}
+	})
+
+	result := make(chan error, 1)
+	go func() {
+		result <- s.Run()
+	}()
+
+	<-started
+
+	err := syscall.Kill(os.Getpid(), syscall.SIGTERM)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	err = <-result
+	if err != nil {
+		t.Fatalf("a graceful stop should not return an error - got %s", err.Error())
+	}
+}
+
+func TestFormatStatus(t *testing.T) {
+	tests := map[string]launchctlutil.StatusDetails{
+		"running (pid 12)":                 {Status: launchctlutil.Running, Pid: 12},
+		"not running (last exit status 1)": {Status: launchctlutil.NotRunning, LastExitStatus: 1},
+		"not installed":                    {Status: launchctlutil.NotInstalled},
+	}
+
+	for exp, details := range tests {
+		if FormatStatus(details) != exp {
+			t.Fatalf("expected '%s' - got '%s'", exp, FormatStatus(details))
+		}
+	}
+
+	if FormatStatus(launchctlutil.StatusDetails{Status: launchctlutil.Unknown}) != "unknown" {
+		t.Fatal("unknown statuses should be described as unknown")
+	}
+}
@@ -0,0 +1,164 @@
+package launchctlutil
+
+import (
+	"fmt"
+	"path"
+)
+
+const (
+	SocketStream    SocketType = "stream"
+	SocketDatagram  SocketType = "dgram"
+	SocketSeqPacket SocketType = "seqpacket"
+)
+
+const (
+	SocketIPv4   SocketFamily = "IPv4"
+	SocketIPv6   SocketFamily = "IPv6"
+	SocketIPv4v6 SocketFamily = "IPv4v6"
+	SocketUnix   SocketFamily = "Unix"
+)
+
+const (
+	SocketTCP SocketProtocol = "TCP"
+	SocketUDP SocketProtocol = "UDP"
+)
+
+// SocketType is the type of a launchd socket (the SockType key).
+type SocketType string
+
+// SocketFamily is the address family of a launchd socket (the
+// SockFamily key).
+type SocketFamily string
+
+// SocketProtocol is the protocol of a launchd socket (the SockProtocol
+// key).
+type SocketProtocol string
+
+// Socket is a socket that launchd listens on for a socket-activated
+// service. launchd starts the service when a connection is made to
+// the socket. Fields left at their zero value are not included in
+// the configuration, which makes launchd use its defaults.
+type Socket struct {
+	// Type is the socket type. launchd defaults to SocketStream.
+	Type SocketType
+
+	// Active makes launchd call connect(2) on the socket instead of
+	// listen(2) by setting SockPassive to false.
+	Active bool
+
+	// NodeName is the host name or address to listen on.
+	NodeName string
+
+	// ServiceName is the port number or service name (e.g., "http")
+	// to listen on.
+	ServiceName string
+
+	// Family is the address family. launchd listens on both IPv4 and
+	// IPv6 by default.
+	Family SocketFamily
+
+	// Protocol is the protocol of the socket.
+	Protocol SocketProtocol
+
+	// PathName is the path of a Unix domain socket. It must be
+	// an absolute path.
+	PathName string
+
+	// PathMode is the file mode of a Unix domain socket
+	// (e.g., 0600).
+	PathMode int
+
+	// Bonjour advertises the socket using Bonjour. The service name
+	// is used as the advertised name unless BonjourNames is set.
+	Bonjour bool
+
+	// BonjourNames are the names to advertise the socket as using
+	// Bonjour.
+	BonjourNames []string
+}
+
+func (o Socket) validate(name string) error {
+	switch o.Type {
+	case "", SocketStream, SocketDatagram, SocketSeqPacket:
+	default:
+		return fmt.Errorf("socket '%s' has an unknown type '%s'", name, o.Type)
+	}
+
+	switch o.Family {
+	case "", SocketIPv4, SocketIPv6, SocketIPv4v6, SocketUnix:
+	default:
+		return fmt.Errorf("socket '%s' has an unknown family '%s'", name, o.Family)
+	}
+
+	switch o.Protocol {
+	case "", SocketTCP, SocketUDP:
+	default:
+		return fmt.Errorf("socket '%s' has an unknown protocol '%s'", name, o.Protocol)
+	}
+
+	if len(o.PathName) > 0 {
+		if !path.IsAbs(o.PathName) {
+			return fmt.Errorf("socket '%s' path name '%s' must be an absolute path", name, o.PathName)
+		}
+
+		if len(o.NodeName) > 0 || len(o.ServiceName) > 0 {
+			return fmt.Errorf("socket '%s' cannot have both a path name and a node or service name", name)
+		}
+	} else if o.Family == SocketUnix {
+		return fmt.Errorf("socket '%s' is a Unix domain socket, but has no path name", name)
+	}
+
+	if o.PathMode < 0 || o.PathMode > 0777 {
+		return fmt.Errorf("socket '%s' path mode %o is invalid", name, o.PathMode)
+	}
+
+	return nil
+}
+
+func (o Socket) plistDict() PlistDict {
+	dict := PlistDict{}
+
+	if len(o.Type) > 0 {
+		dict = append(dict, PlistEntry{Key: "SockType", Value: PlistString(o.Type)})
+	}
+
+	if o.Active {
+		dict = append(dict, PlistEntry{Key: "SockPassive", Value: PlistBool(false)})
+	}
+
+	if len(o.NodeName) > 0 {
+		dict = append(dict, PlistEntry{Key: "SockNodeName", Value: PlistString(o.NodeName)})
+	}
+
+	if len(o.ServiceName) > 0 {
+		dict = append(dict, PlistEntry{Key: "SockServiceName", Value: PlistString(o.ServiceName)})
+	}
+
+	if len(o.Family) > 0 {
+		dict = append(dict, PlistEntry{Key: "SockFamily", Value: PlistString(o.Family)})
+	}
+
+	if len(o.Protocol) > 0 {
+		dict = append(dict, PlistEntry{Key: "SockProtocol", Value: PlistString(o.Protocol)})
+	}
+
+	if len(o.PathName) > 0 {
+		dict = append(dict, PlistEntry{Key: "SockPathName", Value: PlistString(o.PathName)})
+	}
+
+	if o.PathMode > 0 {
+		dict = append(dict, PlistEntry{Key: "SockPathMode", Value: PlistInteger(o.PathMode)})
+	}
+
+	if len(o.BonjourNames) > 0 {
+		names := PlistArray{}
+		for _, name := range o.BonjourNames {
+			names = append(names, PlistString(name))
+		}
+		dict = append(dict, PlistEntry{Key: "Bonjour", Value: names})
+	} else if o.Bonjour {
+		dict = append(dict, PlistEntry{Key: "Bonjour", Value: PlistBool(true)})
+	}
+
+	return dict
+}
@@ -0,0 +1,640 @@
+package launchctlutil
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultSupervisordLabelPrefix = "supervisord"
+	supervisordSource             = "supervisord"
+	supervisordProgramPrefix      = "program:"
+)
+
+var (
+	supervisordExpansion = regexp.MustCompile(`%%|%\(([A-Za-z0-9_]+)\)([-#0 +]*[0-9]*)([sd])`)
+)
+
+// SupervisordImportOptions configures ImportSupervisord.
+type SupervisordImportOptions struct {
+	// LabelPrefix is prepended to each program's name to create its
+	// label. It defaults to "supervisord".
+	LabelPrefix string
+
+	// Kind is the kind of the imported Configurations.
+	Kind Kind
+
+	// Here is the directory that "%(here)s" expands to. It is usually
+	// the directory that contains the configuration file.
+	// ReadSupervisordFile sets it if it is empty.
+	Here string
+}
+
+// ReadSupervisordFile reads a supervisord configuration file and converts
+// its programs to Configurations. See ImportSupervisord for details.
+func ReadSupervisordFile(filePath string, options SupervisordImportOptions) ([]Configuration, []ImportWarning, error) {
+	contents, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(options.Here) == 0 {
+		options.Here, err = filepath.Abs(path.Dir(filePath))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return ImportSupervisord(contents, options)
+}
+
+// ImportSupervisord converts each [program:x] section of a supervisord
+// configuration file to a Configuration. The label of each Configuration
+// is the LabelPrefix followed by the program's name (e.g.,
+// "supervisord.x").
+//
+// The command, directory, environment, user, autostart, autorestart,
+// stdout_logfile, and stderr_logfile settings are converted, along with
+// several others that have launchd equivalents. Settings that could not
+// be converted are returned as warnings. A program that could not be
+// converted at all is skipped and described by a warning.
+func ImportSupervisord(contents []byte, options SupervisordImportOptions) ([]Configuration, []ImportWarning, error) {
+	prefix := options.LabelPrefix
+	if len(prefix) == 0 {
+		prefix = defaultSupervisordLabelPrefix
+	}
+
+	sections, err := parseSupervisordConfig(contents)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse supervisord configuration - %s", err.Error())
+	}
+
+	var configs []Configuration
+	var warnings []ImportWarning
+
+	for _, section := range sections {
+		if !strings.HasPrefix(section.name, supervisordProgramPrefix) {
+			warning, ok := supervisordSectionWarning(section)
+			if ok {
+				warnings = append(warnings, warning)
+			}
+			continue
+		}
+
+		programName := strings.TrimPrefix(section.name, supervisordProgramPrefix)
+
+		importer := &supervisordImporter{
+			builder: NewConfigurationBuilder().
+				SetKind(options.Kind).
+				SetLabel(prefix + "." + programName),
+			kind:    options.Kind,
+			section: section,
+			variables: map[string]string{
+				"program_name": programName,
+				"group_name":   programName,
+				"process_num":  "0",
+				"numprocs":     "1",
+			},
+			autoStart:   true,
+			autoRestart: "unexpected",
+			exitCodes:   "0",
+		}
+
+		if len(options.Here) > 0 {
+			importer.variables["here"] = options.Here
+		}
+
+		config, err := importer.importProgram()
+		warnings = append(warnings, importer.warnings...)
+		if err != nil {
+			warnings = append(warnings, ImportWarning{
+				Source:  supervisordSource,
+				Line:    section.line,
+				Setting: "[" + section.name + "]",
+				Message: "program was not converted - " + err.Error(),
+			})
+			continue
+		}
+
+		configs = append(configs, config)
+	}
+
+	return configs, warnings, nil
+}
+
+// supervisordSection is a section of a supervisord configuration file.
+type supervisordSection struct {
+	name    string
+	line    int
+	options []supervisordOption
+}
+
+// supervisordOption is an option in a section of a supervisord
+// configuration file.
+type supervisordOption struct {
+	section string
+	line    int
+	key     string
+	value   string
+}
+
+func (o supervisordOption) setting() string {
+	return "[" + o.section + "] " + o.key
+}
+
+// parseSupervisordConfig returns the sections of a supervisord
+// configuration file in the order they appear. Like Python's
+// ConfigParser, it accepts "=" and ":" delimiters, inline comments
+// that start with " ;", and indented continuation lines.
+func parseSupervisordConfig(data []byte) ([]supervisordSection, error) {
+	var sections []supervisordSection
+	var current *supervisordSection
+
+	lines := strings.Split(strings.Replace(string(data), "\r", "", -1), "\n")
+
+	for i, rawLine := range lines {
+		lineNumber := i + 1
+		l := strings.TrimSpace(rawLine)
+
+		if len(l) == 0 || l[0] == '#' || l[0] == ';' {
+			continue
+		}
+
+		if rawLine[0] == ' ' || rawLine[0] == '\t' {
+			if current == nil || len(current.options) == 0 {
+				return nil, fmt.Errorf("line %d: unexpected indented line", lineNumber)
+			}
+
+			last := &current.options[len(current.options)-1]
+			last.value = last.value + "\n" + stripSupervisordComment(l)
+			continue
+		}
+
+		if l[0] == '[' {
+			if !strings.HasSuffix(l, "]") {
+				return nil, fmt.Errorf("line %d: invalid section header '%s'", lineNumber, l)
+			}
+
+			sections = append(sections, supervisordSection{
+				name: strings.TrimSpace(l[1 : len(l)-1]),
+				line: lineNumber,
+			})
+			current = &sections[len(sections)-1]
+			continue
+		}
+
+		delimiter := strings.IndexAny(l, "=:")
+		if delimiter < 0 {
+			return nil, fmt.Errorf("line %d: expected a key=value assignment - got '%s'", lineNumber, l)
+		}
+
+		if current == nil {
+			return nil, fmt.Errorf("line %d: assignment is not in a section", lineNumber)
+		}
+
+		current.options = append(current.options, supervisordOption{
+			section: current.name,
+			line:    lineNumber,
+			key:     strings.ToLower(strings.TrimSpace(l[:delimiter])),
+			value:   stripSupervisordComment(strings.TrimSpace(l[delimiter+1:])),
+		})
+	}
+
+	return sections, nil
+}
+
+// stripSupervisordComment removes an inline comment from a value.
+func stripSupervisordComment(value string) string {
+	index := strings.Index(value, " ;")
+	if index < 0 {
+		index = strings.Index(value, "\t;")
+	}
+
+	if index >= 0 {
+		value = value[:index]
+	}
+
+	return strings.TrimSpace(value)
+}
+
+// supervisordSectionWarning returns a warning for a section that is not
+// a program. The sections that configure supervisord itself are ignored
+// without a warning.
+func supervisordSectionWarning(section supervisordSection) (ImportWarning, bool) {
+	warning := ImportWarning{
+		Source:  supervisordSource,
+		Line:    section.line,
+		Setting: "[" + section.name + "]",
+	}
+
+	switch {
+	case section.name == "supervisord", section.name == "supervisorctl",
+		section.name == "unix_http_server", section.name == "inet_http_server",
+		strings.HasPrefix(section.name, "rpcinterface:"):
+		return warning, false
+	case section.name == "include":
+		warning.Message = "included files were not read; convert them separately"
+	case strings.HasPrefix(section.name, "group:"):
+		warning.Message = "launchd does not group services; the group's programs are converted individually"
+	default:
+		warning.Message = "has no launchd equivalent and was ignored"
+	}
+
+	return warning, true
+}
+
+type supervisordImporter struct {
+	builder   ConfigurationBuilder
+	kind      Kind
+	section   supervisordSection
+	variables map[string]string
+	warnings  []ImportWarning
+
+	hasCommand        bool
+	autoStart         bool
+	autoStartOption   supervisordOption
+	autoRestart       string
+	autoRestartOption supervisordOption
+	exitCodes         string
+	exitCodesOption   supervisordOption
+	redirectStderr    bool
+	stdoutPath        string
+	stderrPath        string
+}
+
+func (o *supervisordImporter) warn(option supervisordOption, format string, a ...interface{}) {
+	o.warnings = append(o.warnings, ImportWarning{
+		Source:  supervisordSource,
+		Line:    option.line,
+		Setting: option.setting(),
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+func (o *supervisordImporter) unsupported(option supervisordOption) {
+	o.warn(option, "has no launchd equivalent and was ignored")
+}
+
+func (o *supervisordImporter) importProgram() (Configuration, error) {
+	for _, option := range o.section.options {
+		value, err := expandSupervisordValue(option.value, o.variables)
+		if err != nil {
+			o.warn(option, "'%s' could not be converted - %s", option.value, err.Error())
+			continue
+		}
+
+		option.value = value
+
+		err = o.importOption(option)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := o.finish()
+	if err != nil {
+		return nil, err
+	}
+
+	return o.builder.Build()
+}
+
+func (o *supervisordImporter) importOption(option supervisordOption) error {
+	switch option.key {
+	case "command":
+		argv, err := splitShellWords(option.value)
+		if err != nil {
+			return fmt.Errorf("failed to parse command - %s", err.Error())
+		}
+
+		if len(argv) == 0 {
+			return errors.New("the command is empty")
+		}
+
+		o.builder.SetCommand(argv[0])
+		for _, arg := range argv[1:] {
+			o.builder.AddArgument(arg)
+		}
+		o.hasCommand = true
+	case "directory":
+		if !path.IsAbs(option.value) {
+			o.warn(option, "'%s' is not an absolute path and was ignored", option.value)
+			return nil
+		}
+
+		o.builder.SetWorkingDirectory(option.value)
+	case "environment":
+		environment, err := parseSupervisordEnvironment(option.value)
+		if err != nil {
+			o.warn(option, "could not be converted - %s", err.Error())
+			return nil
+		}
+
+		for _, entry := range environment {
+			o.builder.AddEnvironmentVariable(entry.Key, string(entry.Value.(PlistString)))
+		}
+	case "user":
+		if !o.kind.isSystemDomain() {
+			o.warn(option, "ignored because agents run as the user that loads them")
+			return nil
+		}
+
+		o.builder.SetUserName(option.value)
+	case "autostart":
+		autoStart, err := parseSupervisordBool(option.value)
+		if err != nil {
+			o.warn(option, "'%s' could not be converted - %s", option.value, err.Error())
+			return nil
+		}
+
+		o.autoStart = autoStart
+		o.autoStartOption = option
+	case "autorestart":
+		o.autoRestart = strings.ToLower(option.value)
+		o.autoRestartOption = option
+	case "exitcodes":
+		o.exitCodes = strings.Replace(option.value, " ", "", -1)
+		o.exitCodesOption = option
+	case "stdout_logfile":
+		o.stdoutPath, _ = o.parseLogFile(option)
+	case "stderr_logfile":
+		o.stderrPath, _ = o.parseLogFile(option)
+	case "redirect_stderr":
+		redirect, err := parseSupervisordBool(option.value)
+		if err != nil {
+			o.warn(option, "'%s' could not be converted - %s", option.value, err.Error())
+			return nil
+		}
+
+		o.redirectStderr = redirect
+	case "umask":
+		umask, err := strconv.ParseInt(option.value, 8, 32)
+		if err != nil {
+			o.warn(option, "'%s' is not an octal number", option.value)
+			return nil
+		}
+
+		o.builder.SetUmask(int(umask))
+	case "stopwaitsecs":
+		seconds, err := strconv.Atoi(option.value)
+		if err != nil || seconds < 0 {
+			o.warn(option, "'%s' is not a number of seconds", option.value)
+			return nil
+		}
+
+		// supervisord defaults to 10 seconds, while launchd defaults
+		// to 20 seconds.
+		o.builder.SetExitTimeOut(time.Duration(seconds) * time.Second)
+	case "stopsignal":
+		signal := strings.TrimPrefix(strings.ToUpper(option.value), "SIG")
+		if signal != "TERM" {
+			o.warn(option, "launchd always stops services with SIGTERM")
+		}
+	case "numprocs":
+		if option.value != "1" {
+			o.warn(option, "launchd runs one process per service; convert each process separately")
+		}
+	case "process_name", "priority":
+		// These only affect supervisord's own bookkeeping.
+	case "stdout_logfile_maxbytes", "stdout_logfile_backups",
+		"stderr_logfile_maxbytes", "stderr_logfile_backups":
+		o.warn(option, "launchd does not rotate log files")
+	default:
+		o.unsupported(option)
+	}
+
+	return nil
+}
+
+// parseLogFile parses a stdout_logfile or stderr_logfile value. It returns
+// the log file's path and true if the output is written to a file.
+func (o *supervisordImporter) parseLogFile(option supervisordOption) (string, bool) {
+	switch strings.ToUpper(option.value) {
+	case "NONE":
+		return "", false
+	case "AUTO":
+		o.warn(option, "launchd does not create log files automatically; the output is discarded")
+		return "", false
+	case "SYSLOG":
+		o.warn(option, "launchd cannot send output to syslog; the output is discarded")
+		return "", false
+	}
+
+	if !path.IsAbs(option.value) {
+		o.warn(option, "'%s' is not an absolute path and was ignored", option.value)
+		return "", false
+	}
+
+	return option.value, true
+}
+
+func (o *supervisordImporter) finish() error {
+	if !o.hasCommand {
+		return errors.New("the program does not have a command")
+	}
+
+	if len(o.stdoutPath) > 0 {
+		o.builder.SetStandardOutPath(o.stdoutPath)
+	}
+
+	if o.redirectStderr {
+		if len(o.stdoutPath) > 0 {
+			o.builder.SetStandardErrorPath(o.stdoutPath)
+		}
+	} else if len(o.stderrPath) > 0 {
+		o.builder.SetStandardErrorPath(o.stderrPath)
+	}
+
+	switch o.autoRestart {
+	case "false":
+	case "true":
+		o.builder.SetKeepAlive(true)
+	case "unexpected":
+		o.builder.SetKeepAliveConditions(KeepAliveConditions{
+			SuccessfulExit: boolPointer(false),
+		})
+
+		if o.exitCodes != "0" {
+			o.warn(o.exitCodesOption, "launchd only treats an exit code of 0 as expected; "+
+				"the program is restarted when it exits with any other code")
+		}
+	default:
+		o.warn(o.autoRestartOption, "'%s' has no launchd equivalent and was ignored", o.autoRestart)
+	}
+
+	if o.autoStart {
+		o.builder.SetRunAtLoad(true)
+	} else if o.autoRestart == "true" || o.autoRestart == "unexpected" {
+		// KeepAlive implies RunAtLoad.
+		o.warn(o.autoStartOption, "launchd starts services that it keeps alive when they are loaded")
+	}
+
+	return nil
+}
+
+// expandSupervisordValue expands the "%(name)s" expressions in a value
+// using the given variables.
+func expandSupervisordValue(value string, variables map[string]string) (string, error) {
+	var expandErr error
+
+	expanded := supervisordExpansion.ReplaceAllStringFunc(value, func(expression string) string {
+		if expression == "%%" {
+			return "%"
+		}
+
+		match := supervisordExpansion.FindStringSubmatch(expression)
+
+		variable, ok := variables[match[1]]
+		if !ok {
+			if expandErr == nil {
+				expandErr = fmt.Errorf("'%%(%s)' cannot be expanded outside of supervisord", match[1])
+			}
+			return expression
+		}
+
+		if match[3] == "d" {
+			number, err := strconv.Atoi(variable)
+			if err != nil {
+				if expandErr == nil {
+					expandErr = fmt.Errorf("'%%(%s)' is not a number", match[1])
+				}
+				return expression
+			}
+
+			return fmt.Sprintf("%"+match[2]+"d", number)
+		}
+
+		return fmt.Sprintf("%"+match[2]+"s", variable)
+	})
+
+	if expandErr != nil {
+		return "", expandErr
+	}
+
+	return expanded, nil
+}
+
+// splitShellWords splits a command line into words like Python's
+// shlex.split, which supervisord uses. Backslashes are literal inside
+// of single quotes, and only escape '"' and '\' inside of double quotes.
+func splitShellWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	var quote rune
+	inWord := false
+	escaped := false
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			if quote == '"' && r != '"' && r != '\\' {
+				word.WriteRune('\\')
+			}
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\r' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if escaped {
+		return nil, errors.New("trailing backslash")
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+// parseSupervisordEnvironment parses an environment setting such as
+// `A="1",B="2"`.
+func parseSupervisordEnvironment(value string) (PlistDict, error) {
+	environment := PlistDict{}
+
+	var pairs []string
+	var pair strings.Builder
+	var quote rune
+
+	for _, r := range value {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+			pair.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			pair.WriteRune(r)
+		case r == ',':
+			pairs = append(pairs, pair.String())
+			pair.Reset()
+		default:
+			pair.WriteRune(r)
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+
+	pairs = append(pairs, pair.String())
+
+	for _, p := range pairs {
+		p = strings.TrimSpace(p)
+		if len(p) == 0 {
+			continue
+		}
+
+		parts := strings.SplitN(p, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("expected KEY=value - got '%s'", p)
+		}
+
+		setPlistString(&environment, strings.TrimSpace(parts[0]), unquoteValue(parts[1]))
+	}
+
+	return environment, nil
+}
+
+func parseSupervisordBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "true", "yes", "on", "1":
+		return true, nil
+	case "false", "no", "off", "0":
+		return false, nil
+	}
+
+	return false, errors.New("expected true or false")
+}
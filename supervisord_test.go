@@ -0,0 +1,205 @@
+package launchctlutil
+
+import (
+	"strings"
+	"testing"
+)
+
+const testSupervisordConfig = `[supervisord]
+logfile=/tmp/supervisord.log
+
+[program:web]
+command=/usr/local/bin/web --port 8080
+    --name "%(program_name)s server"
+directory=%(here)s/web
+environment=MODE="prod",GREETING="hello, world"
+user=www
+autorestart=true
+stdout_logfile=/var/log/web.log ; The access log.
+redirect_stderr=true
+startsecs=5
+
+[program:worker]
+command=/usr/local/bin/worker
+autostart=false
+autorestart=unexpected
+exitcodes=0,2
+stdout_logfile=AUTO
+stderr_logfile=/var/log/worker.err
+
+[program:broken]
+directory=/tmp
+
+[group:all]
+programs=web,worker
+`
+
+func TestImportSupervisord(t *testing.T) {
+	configs, warnings, err := ImportSupervisord([]byte(testSupervisordConfig), SupervisordImportOptions{
+		Kind: Daemon,
+		Here: "/srv",
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(configs) != 2 {
+		t.Fatalf("expected 2 configurations - got %d", len(configs))
+	}
+
+	web, err := ConfigurationPlist(configs[0])
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	label, _ := web.GetString("Label")
+	if label != "supervisord.web" {
+		t.Fatalf("unexpected label - got '%s'", label)
+	}
+
+	args, _ := web.GetStrings("ProgramArguments")
+	expArgs := []string{"/usr/local/bin/web", "--port", "8080", "--name", "web server"}
+	if strings.Join(args, "|") != strings.Join(expArgs, "|") {
+		t.Fatalf("unexpected program arguments - got %q", args)
+	}
+
+	for key, exp := range map[string]string{
+		"WorkingDirectory":  "/srv/web",
+		"UserName":          "www",
+		"StandardOutPath":   "/var/log/web.log",
+		"StandardErrorPath": "/var/log/web.log",
+	} {
+		value, _ := web.GetString(key)
+		if value != exp {
+			t.Fatalf("%s should be '%s' - got '%s'", key, exp, value)
+		}
+	}
+
+	environment, _ := web.Get("EnvironmentVariables")
+	greeting, _ := environment.(PlistDict).GetString("GREETING")
+	if greeting != "hello, world" {
+		t.Fatalf("unexpected GREETING - got '%s'", greeting)
+	}
+
+	keepAlive, _ := web.GetBool("KeepAlive")
+	runAtLoad, _ := web.GetBool("RunAtLoad")
+	if !keepAlive || !runAtLoad {
+		t.Fatal("web should be kept alive and run at load")
+	}
+
+	worker, err := ConfigurationPlist(configs[1])
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	conditions, _ := worker.Get("KeepAlive")
+	successfulExit, ok := conditions.(PlistDict).GetBool("SuccessfulExit")
+	if !ok || successfulExit {
+		t.Fatalf("worker should be restarted after unsuccessful exits - got %v", conditions)
+	}
+
+	stdout, _ := worker.GetString("StandardOutPath")
+	stderr, _ := worker.GetString("StandardErrorPath")
+	if len(stdout) > 0 || stderr != "/var/log/worker.err" {
+		t.Fatalf("unexpected log paths - got '%s' and '%s'", stdout, stderr)
+	}
+
+	var messages []string
+	for _, warning := range warnings {
+		messages = append(messages, warning.String())
+	}
+
+	expWarnings := []string{
+		"supervisord:13: [program:web] startsecs",
+		"supervisord:20: [program:worker] stdout_logfile",
+		"supervisord:19: [program:worker] exitcodes",
+		"supervisord:17: [program:worker] autostart",
+		"supervisord:23: [program:broken]: program was not converted",
+		"supervisord:26: [group:all]",
+	}
+
+	if len(messages) != len(expWarnings) {
+		t.Fatalf("expected %d warnings - got %q", len(expWarnings), messages)
+	}
+
+	for i, exp := range expWarnings {
+		if !strings.HasPrefix(messages[i], exp) {
+			t.Fatalf("warning %d should start with '%s' - got '%s'", i, exp, messages[i])
+		}
+	}
+}
+
+func TestImportSupervisordAgentUser(t *testing.T) {
+	config := "[program:x]\ncommand=/bin/x\nuser=nobody\nnumprocs=2\n"
+
+	configs, warnings, err := ImportSupervisord([]byte(config), SupervisordImportOptions{Kind: UserAgent})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	dict, _ := ConfigurationPlist(configs[0])
+	_, ok := dict.Get("UserName")
+	if ok {
+		t.Fatal("agents should not have a UserName")
+	}
+
+	if len(warnings) != 2 || warnings[0].Setting != "[program:x] user" || warnings[1].Setting != "[program:x] numprocs" {
+		t.Fatalf("unexpected warnings - got %q", warnings)
+	}
+}
+
+func TestExpandSupervisordValue(t *testing.T) {
+	variables := map[string]string{"program_name": "web", "process_num": "3"}
+
+	expanded, err := expandSupervisordValue("%(program_name)s_%(process_num)02d 100%%", variables)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if expanded != "web_03 100%" {
+		t.Fatalf("unexpected expansion - got '%s'", expanded)
+	}
+
+	_, err = expandSupervisordValue("%(ENV_HOME)s/bin", variables)
+	if err == nil {
+		t.Fatal("unknown variables should not be expanded")
+	}
+
+	expanded, err = expandSupervisordValue("%%(ENV_HOME)s %(name)s", map[string]string{"name": "100%%"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if expanded != "%(ENV_HOME)s 100%%" {
+		t.Fatalf("unexpected expansion of escaped percent signs - got '%s'", expanded)
+	}
+}
+
+func TestSplitShellWords(t *testing.T) {
+	tests := []struct {
+		s   string
+		exp []string
+	}{
+		{s: `echo 'a\b' '\n'`, exp: []string{"echo", `a\b`, `\n`}},
+		{s: `echo "a\"b\\c\n" d\ e`, exp: []string{"echo", `a"b\c\n`, "d e"}},
+		{s: `echo '' "x"y`, exp: []string{"echo", "", "xy"}},
+	}
+
+	for _, test := range tests {
+		words, err := splitShellWords(test.s)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if strings.Join(words, "|") != strings.Join(test.exp, "|") || len(words) != len(test.exp) {
+			t.Fatalf("expected %q for %s - got %q", test.exp, test.s, words)
+		}
+	}
+
+	for _, s := range []string{`echo 'a`, `echo a\`} {
+		_, err := splitShellWords(s)
+		if err == nil {
+			t.Fatalf("expected an error for %s", s)
+		}
+	}
+}
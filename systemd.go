@@ -0,0 +1,1247 @@
+package launchctlutil
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	systemdServiceSuffix = ".service"
+	systemdTimerSuffix   = ".timer"
+	systemdServiceSource = "service"
+	systemdTimerSource   = "timer"
+
+	// maxCalendarIntervals is the maximum number of StartCalendarInterval
+	// entries that a systemd calendar event may expand to.
+	maxCalendarIntervals = 1024
+)
+
+// SystemdImportOptions configures ImportSystemdUnit.
+type SystemdImportOptions struct {
+	// Label is the label of the imported Configuration. It is
+	// required by ImportSystemdUnit. ReadSystemdUnitFile uses the
+	// unit's name if it is empty.
+	Label string
+
+	// Kind is the kind of the imported Configuration.
+	Kind Kind
+
+	// Timer is the contents of the .timer unit that activates the
+	// service, if any. ReadSystemdUnitFile reads the .timer file next
+	// to the .service file if it is nil.
+	Timer []byte
+}
+
+// ReadSystemdUnitFile reads a systemd .service unit file and converts it
+// to a Configuration. See ImportSystemdUnit for details.
+func ReadSystemdUnitFile(servicePath string, options SystemdImportOptions) (Configuration, []ImportWarning, error) {
+	service, err := ioutil.ReadFile(servicePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	unitName := strings.TrimSuffix(path.Base(servicePath), systemdServiceSuffix)
+
+	if len(options.Label) == 0 {
+		options.Label = unitName
+	}
+
+	if options.Timer == nil {
+		timerPath := path.Join(path.Dir(servicePath), unitName+systemdTimerSuffix)
+		timer, err := ioutil.ReadFile(timerPath)
+		if err == nil {
+			options.Timer = timer
+		} else if !os.IsNotExist(err) {
+			return nil, nil, err
+		}
+	}
+
+	return ImportSystemdUnit(service, options)
+}
+
+// ImportSystemdUnit converts the contents of a systemd .service unit, and
+// optionally the .timer unit that activates it, to a Configuration.
+//
+// ExecStart, Environment, EnvironmentFile, WorkingDirectory, User, Group,
+// Restart, the Limit* resource limits, and the standard I/O settings of
+// the service are converted, as are the OnCalendar, OnUnitActiveSec, and
+// OnBootSec settings of the timer. Variables from environment files are
+// copied into the Configuration. A warning is returned for each setting
+// that has no launchd equivalent, or that could only be approximated.
+func ImportSystemdUnit(service []byte, options SystemdImportOptions) (Configuration, []ImportWarning, error) {
+	if len(options.Label) == 0 {
+		return nil, nil, errors.New("a label is required to import a systemd unit")
+	}
+
+	serviceDirectives, err := parseSystemdUnit(service, systemdServiceSource)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse service unit - %s", err.Error())
+	}
+
+	var timerDirectives []systemdDirective
+	if options.Timer != nil {
+		timerDirectives, err = parseSystemdUnit(options.Timer, systemdTimerSource)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse timer unit - %s", err.Error())
+		}
+	}
+
+	importer := &systemdImporter{
+		builder: NewConfigurationBuilder().
+			SetKind(options.Kind).
+			SetLabel(options.Label),
+		kind:        options.Kind,
+		hasTimer:    options.Timer != nil,
+		environment: PlistDict{},
+	}
+
+	for _, directive := range serviceDirectives {
+		err := importer.importServiceDirective(directive)
+		if err != nil {
+			return nil, importer.warnings, err
+		}
+	}
+
+	for _, directive := range timerDirectives {
+		importer.importTimerDirective(directive)
+	}
+
+	err = importer.finish()
+	if err != nil {
+		return nil, importer.warnings, err
+	}
+
+	config, err := importer.builder.Build()
+	if err != nil {
+		return nil, importer.warnings, err
+	}
+
+	return config, importer.warnings, nil
+}
+
+// systemdDirective is a "key=value" assignment in a unit file.
+type systemdDirective struct {
+	source  string
+	line    int
+	section string
+	key     string
+	value   string
+}
+
+func (o systemdDirective) setting() string {
+	return "[" + o.section + "] " + o.key
+}
+
+// parseSystemdUnit returns the assignments in a unit file in the order
+// they appear.
+func parseSystemdUnit(data []byte, source string) ([]systemdDirective, error) {
+	var directives []systemdDirective
+	var section string
+
+	lines := strings.Split(strings.Replace(string(data), "\r", "", -1), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		lineNumber := i + 1
+		l := strings.TrimSpace(lines[i])
+
+		if len(l) == 0 || l[0] == '#' || l[0] == ';' {
+			continue
+		}
+
+		if l[0] == '[' {
+			if !strings.HasSuffix(l, "]") {
+				return nil, fmt.Errorf("line %d: invalid section header '%s'", lineNumber, l)
+			}
+			section = l[1 : len(l)-1]
+			continue
+		}
+
+		for strings.HasSuffix(l, `\`) {
+			l = strings.TrimSuffix(l, `\`)
+			if i+1 >= len(lines) {
+				break
+			}
+
+			i++
+			next := strings.TrimSpace(lines[i])
+			if len(next) > 0 && (next[0] == '#' || next[0] == ';') {
+				// Comments inside of a continued line are ignored.
+				l = l + `\`
+				continue
+			}
+
+			l = l + " " + next
+		}
+
+		parts := strings.SplitN(l, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: expected a key=value assignment - got '%s'", lineNumber, l)
+		}
+
+		if len(section) == 0 {
+			return nil, fmt.Errorf("line %d: assignment is not in a section", lineNumber)
+		}
+
+		directives = append(directives, systemdDirective{
+			source:  source,
+			line:    lineNumber,
+			section: section,
+			key:     strings.TrimSpace(parts[0]),
+			value:   strings.TrimSpace(parts[1]),
+		})
+	}
+
+	return directives, nil
+}
+
+type systemdImporter struct {
+	builder  ConfigurationBuilder
+	kind     Kind
+	warnings []ImportWarning
+
+	hasExecStart bool
+	program      string
+	argv         []string
+
+	environment PlistDict
+	fileEnv     PlistDict
+
+	stdoutPath   string
+	stderrPath   string
+	stderrNull   bool
+	hasInstall   bool
+	hasTimer     bool
+	runAtLoad    bool
+	interval     time.Duration
+	numIntervals int
+
+	// delayedActivations are the OnBootSec, OnStartupSec, and
+	// OnActiveSec settings that have a non-zero delay.
+	delayedActivations []systemdDirective
+}
+
+func (o *systemdImporter) warn(directive systemdDirective, format string, a ...interface{}) {
+	o.warnings = append(o.warnings, ImportWarning{
+		Source:  directive.source,
+		Line:    directive.line,
+		Setting: directive.setting(),
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+func (o *systemdImporter) unsupported(directive systemdDirective) {
+	o.warn(directive, "has no launchd equivalent and was ignored")
+}
+
+func (o *systemdImporter) importServiceDirective(d systemdDirective) error {
+	switch d.section {
+	case "Service":
+	case "Install":
+		switch d.key {
+		case "WantedBy", "RequiredBy", "UpheldBy":
+			o.hasInstall = o.hasInstall || len(d.value) > 0
+		default:
+			o.unsupported(d)
+		}
+		return nil
+	default:
+		o.unsupported(d)
+		return nil
+	}
+
+	switch d.key {
+	case "ExecStart":
+		return o.importExecStart(d)
+	case "Type":
+		switch d.value {
+		case "simple", "exec", "oneshot":
+		case "forking":
+			o.warn(d, "launchd requires services to run in the foreground rather than fork")
+		default:
+			o.warn(d, "service type '%s' has no launchd equivalent; it is treated as 'simple'", d.value)
+		}
+	case "Environment":
+		if len(d.value) == 0 {
+			o.environment = PlistDict{}
+			return nil
+		}
+
+		words, err := splitSystemdWords(d.value)
+		if err != nil {
+			o.warn(d, "could not be parsed - %s", err.Error())
+			return nil
+		}
+
+		for _, word := range words {
+			parts := strings.SplitN(word, "=", 2)
+			if len(parts) != 2 || len(parts[0]) == 0 {
+				o.warn(d, "'%s' is not a NAME=VALUE assignment and was ignored", word)
+				continue
+			}
+
+			setPlistString(&o.environment, parts[0], parts[1])
+		}
+	case "EnvironmentFile":
+		o.importEnvironmentFile(d)
+	case "WorkingDirectory", "RootDirectory":
+		dirPath := strings.TrimPrefix(d.value, "-")
+		if !path.IsAbs(dirPath) {
+			o.warn(d, "'%s' is not an absolute path and was ignored", d.value)
+			return nil
+		}
+
+		if d.key == "WorkingDirectory" {
+			o.builder.SetWorkingDirectory(dirPath)
+		} else {
+			o.builder.SetRootDirectory(dirPath)
+		}
+	case "User", "Group":
+		if !o.kind.isSystemDomain() {
+			o.warn(d, "launchd only runs daemons as a different user or group; it was ignored for the %s kind", o.kind)
+			return nil
+		}
+
+		if d.key == "User" {
+			o.builder.SetUserName(d.value)
+		} else {
+			o.builder.SetGroupName(d.value)
+		}
+	case "UMask":
+		umask, err := strconv.ParseUint(d.value, 8, 32)
+		if err != nil {
+			o.warn(d, "'%s' is not an octal umask and was ignored", d.value)
+			return nil
+		}
+
+		o.builder.SetUmask(int(umask))
+	case "Nice":
+		nice, err := strconv.Atoi(d.value)
+		if err != nil || nice < minNice || nice > maxNice {
+			o.warn(d, "'%s' is not a valid nice value and was ignored", d.value)
+			return nil
+		}
+
+		o.builder.SetNice(nice)
+	case "Restart":
+		o.importRestart(d)
+	case "RestartSec":
+		interval, ok := o.parseDuration(d)
+		if ok {
+			o.builder.SetThrottleInterval(interval)
+		}
+	case "TimeoutStopSec", "TimeoutSec":
+		if d.key == "TimeoutSec" {
+			o.warn(d, "only the stop timeout was converted; launchd does not have a start timeout")
+		}
+
+		timeout, ok := o.parseDuration(d)
+		if ok {
+			o.builder.SetExitTimeOut(timeout)
+		}
+	case "StandardInput":
+		switch {
+		case strings.HasPrefix(d.value, "file:"):
+			o.builder.SetStandardInPath(strings.TrimPrefix(d.value, "file:"))
+		case d.value == "null":
+		default:
+			o.warn(d, "'%s' has no launchd equivalent; stdin is read from /dev/null", d.value)
+		}
+	case "StandardOutput", "StandardError":
+		filePath, isFile := o.parseOutput(d)
+		if d.key == "StandardOutput" {
+			o.stdoutPath = filePath
+		} else {
+			o.stderrPath = filePath
+			o.stderrNull = !isFile && d.value != "inherit"
+		}
+	default:
+		resource, isLimit := systemdResourceLimits[d.key]
+		if isLimit {
+			o.importResourceLimit(d, resource)
+			return nil
+		}
+
+		o.unsupported(d)
+	}
+
+	return nil
+}
+
+func (o *systemdImporter) importExecStart(d systemdDirective) error {
+	if len(d.value) == 0 {
+		o.hasExecStart = false
+		return nil
+	}
+
+	if o.hasExecStart {
+		o.warn(d, "launchd runs a single command; only the first ExecStart was converted")
+		return nil
+	}
+
+	command := d.value
+	useArgv0 := false
+	expandsVariables := true
+
+	for len(command) > 0 && strings.ContainsRune("@-:+!", rune(command[0])) {
+		switch command[0] {
+		case '@':
+			useArgv0 = true
+		case ':':
+			expandsVariables = false
+		default:
+			o.warn(d, "the '%c' prefix has no launchd equivalent and was ignored", command[0])
+		}
+		command = command[1:]
+	}
+
+	words, err := splitSystemdWords(command)
+	if err != nil {
+		return fmt.Errorf("%s:%d: failed to parse ExecStart - %s", d.source, d.line, err.Error())
+	}
+
+	if useArgv0 {
+		if len(words) < 2 {
+			return fmt.Errorf("%s:%d: ExecStart with the '@' prefix requires an argv[0]", d.source, d.line)
+		}
+
+		o.program = words[0]
+		words = words[1:]
+	}
+
+	if len(words) == 0 {
+		return fmt.Errorf("%s:%d: ExecStart does not contain a command", d.source, d.line)
+	}
+
+	hasSpecifier := false
+	hasVariable := false
+
+	for i, word := range words {
+		unescaped := strings.Replace(word, "%%", "", -1)
+		if strings.Contains(unescaped, "%") {
+			hasSpecifier = true
+		}
+
+		if expandsVariables && strings.Contains(word, "$") {
+			hasVariable = true
+		}
+
+		words[i] = strings.Replace(word, "%%", "%", -1)
+	}
+
+	if hasSpecifier {
+		o.warn(d, "unit specifiers (e.g., '%%h') are not expanded by launchd")
+	}
+
+	if hasVariable {
+		o.warn(d, "environment variable references are not expanded by launchd")
+	}
+
+	o.hasExecStart = true
+	o.argv = words
+
+	return nil
+}
+
+func (o *systemdImporter) importEnvironmentFile(d systemdDirective) {
+	if len(d.value) == 0 {
+		o.fileEnv = nil
+		return
+	}
+
+	isOptional := strings.HasPrefix(d.value, "-")
+	filePath := strings.TrimPrefix(d.value, "-")
+
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		if isOptional {
+			o.warn(d, "optional file could not be read and was skipped - %s", err.Error())
+		} else {
+			o.warn(d, "file could not be read; its variables are missing - %s", err.Error())
+		}
+		return
+	}
+
+	variables, err := parseEnvironmentFile(data)
+	if err != nil {
+		o.warn(d, "file could not be parsed - %s", err.Error())
+		return
+	}
+
+	for _, entry := range variables {
+		setPlistString(&o.fileEnv, entry.Key, string(entry.Value.(PlistString)))
+	}
+
+	o.warn(d, "variables were copied from '%s'; later changes to the file will not be used by launchd", filePath)
+}
+
+func (o *systemdImporter) importRestart(d systemdDirective) {
+	switch d.value {
+	case "no":
+	case "always":
+		o.builder.SetKeepAlive(true)
+	case "on-success":
+		o.builder.SetKeepAliveConditions(KeepAliveConditions{
+			SuccessfulExit: boolPointer(true),
+		})
+	case "on-failure":
+		o.builder.SetKeepAliveConditions(KeepAliveConditions{
+			SuccessfulExit: boolPointer(false),
+		})
+	case "on-abort":
+		o.builder.SetKeepAliveConditions(KeepAliveConditions{
+			Crashed: boolPointer(true),
+		})
+	case "on-abnormal":
+		o.builder.SetKeepAliveConditions(KeepAliveConditions{
+			Crashed: boolPointer(true),
+		})
+		o.warn(d, "approximated by restarting when the service exits due to a signal; timeouts do not restart it")
+	default:
+		o.warn(d, "'%s' has no launchd equivalent and was ignored", d.value)
+	}
+}
+
+func (o *systemdImporter) importResourceLimit(d systemdDirective, resource Resource) {
+	parts := strings.SplitN(d.value, ":", 2)
+	soft := parts[0]
+	hard := parts[0]
+	if len(parts) == 2 {
+		hard = parts[1]
+	}
+
+	var values [2]*int
+
+	for i, value := range []string{soft, hard} {
+		if value == "infinity" {
+			continue
+		}
+
+		limit, err := parseSystemdResourceLimit(value, resource)
+		if err != nil {
+			o.warn(d, "'%s' could not be converted - %s", value, err.Error())
+			continue
+		}
+
+		values[i] = &limit
+	}
+
+	if values[0] != nil && values[1] != nil && *values[0] > *values[1] {
+		o.warn(d, "the soft limit exceeds the hard limit and was ignored")
+		return
+	}
+
+	if values[0] != nil {
+		o.builder.SetSoftResourceLimit(resource, *values[0])
+	}
+
+	if values[1] != nil {
+		o.builder.SetHardResourceLimit(resource, *values[1])
+	}
+}
+
+// parseDuration parses a systemd time span. Sub-second durations are
+// rounded up to one second because launchd uses whole seconds.
+func (o *systemdImporter) parseDuration(d systemdDirective) (time.Duration, bool) {
+	if d.value == "infinity" {
+		return 0, true
+	}
+
+	duration, err := parseSystemdTimeSpan(d.value)
+	if err != nil {
+		o.warn(d, "'%s' could not be converted - %s", d.value, err.Error())
+		return 0, false
+	}
+
+	if duration > 0 && duration < time.Second {
+		o.warn(d, "rounded up to one second because launchd uses whole seconds")
+		duration = time.Second
+	}
+
+	return duration, true
+}
+
+// parseOutput parses a StandardOutput or StandardError value. It returns
+// the output file's path and true if the output is written to a file.
+func (o *systemdImporter) parseOutput(d systemdDirective) (string, bool) {
+	switch {
+	case strings.HasPrefix(d.value, "file:"):
+		return strings.TrimPrefix(d.value, "file:"), true
+	case strings.HasPrefix(d.value, "append:"):
+		return strings.TrimPrefix(d.value, "append:"), true
+	case strings.HasPrefix(d.value, "truncate:"):
+		o.warn(d, "launchd appends to the file rather than truncating it")
+		return strings.TrimPrefix(d.value, "truncate:"), true
+	case d.value == "null":
+	case d.value == "inherit" && d.key == "StandardError":
+	default:
+		o.warn(d, "'%s' has no launchd equivalent; the output is discarded", d.value)
+	}
+
+	return "", false
+}
+
+func (o *systemdImporter) importTimerDirective(d systemdDirective) {
+	if d.section == "Install" {
+		// The timer is enabled by loading the service.
+		return
+	}
+
+	if d.section != "Timer" {
+		o.unsupported(d)
+		return
+	}
+
+	switch d.key {
+	case "OnCalendar":
+		if len(d.value) == 0 {
+			o.warn(d, "resetting calendar events is not supported and was ignored")
+			return
+		}
+
+		intervals, err := parseSystemdCalendar(d.value)
+		if err != nil {
+			o.warn(d, "'%s' could not be converted - %s", d.value, err.Error())
+			return
+		}
+
+		for _, interval := range intervals {
+			if interval.Day != nil && interval.Weekday != nil {
+				o.warn(d, "launchd starts the service when either the day or the weekday matches, rather than both")
+				break
+			}
+		}
+
+		for _, interval := range intervals {
+			o.builder.AddStartCalendarInterval(interval)
+		}
+		o.numIntervals += len(intervals)
+	case "OnUnitActiveSec", "OnUnitInactiveSec":
+		if o.interval > 0 {
+			o.warn(d, "launchd supports a single start interval; only the first was converted")
+			return
+		}
+
+		interval, ok := o.parseDuration(d)
+		if !ok {
+			return
+		}
+
+		if interval == 0 {
+			o.warn(d, "a zero interval has no launchd equivalent and was ignored")
+			return
+		}
+
+		if d.key == "OnUnitInactiveSec" {
+			o.warn(d, "launchd measures the interval from when the service starts rather than when it stops")
+		}
+
+		o.builder.SetStartIntervalDuration(interval)
+		o.interval = interval
+	case "OnBootSec", "OnStartupSec", "OnActiveSec":
+		delay, err := parseSystemdTimeSpan(d.value)
+		if err == nil && delay == 0 {
+			o.runAtLoad = true
+			return
+		}
+
+		o.delayedActivations = append(o.delayedActivations, d)
+	case "Persistent":
+		persistent, err := parseSystemdBool(d.value)
+		if err == nil && !persistent {
+			return
+		}
+
+		o.warn(d, "launchd runs missed calendar events after the computer wakes from sleep, but not after it was powered off")
+	default:
+		o.unsupported(d)
+	}
+}
+
+func (o *systemdImporter) finish() error {
+	if !o.hasExecStart {
+		return errors.New("the service does not have an ExecStart command")
+	}
+
+	if len(o.program) > 0 {
+		o.builder.SetProgram(o.program)
+	}
+
+	o.builder.SetCommand(o.argv[0])
+	for _, arg := range o.argv[1:] {
+		o.builder.AddArgument(arg)
+	}
+
+	// Variables from environment files override those set by
+	// Environment, regardless of the order they appear in.
+	for _, entry := range o.fileEnv {
+		setPlistString(&o.environment, entry.Key, string(entry.Value.(PlistString)))
+	}
+
+	for _, entry := range o.environment {
+		o.builder.AddEnvironmentVariable(entry.Key, string(entry.Value.(PlistString)))
+	}
+
+	if len(o.stdoutPath) > 0 {
+		o.builder.SetStandardOutPath(o.stdoutPath)
+	}
+
+	// stderr is written to the same place as stdout by default.
+	if len(o.stderrPath) > 0 {
+		o.builder.SetStandardErrorPath(o.stderrPath)
+	} else if len(o.stdoutPath) > 0 && !o.stderrNull {
+		o.builder.SetStandardErrorPath(o.stdoutPath)
+	}
+
+	for _, d := range o.delayedActivations {
+		// launchd first starts a service with a StartInterval when the
+		// interval elapses after it is loaded.
+		delay, err := parseSystemdTimeSpan(d.value)
+		if err == nil && delay == o.interval {
+			continue
+		}
+
+		o.runAtLoad = true
+		o.warn(d, "the service is started when it is loaded; the delay of '%s' has no launchd equivalent", d.value)
+	}
+
+	switch {
+	case o.runAtLoad || (o.hasInstall && !o.hasTimer):
+		o.builder.SetRunAtLoad(true)
+	case o.hasTimer && o.numIntervals == 0 && o.interval == 0:
+		o.warnings = append(o.warnings, ImportWarning{
+			Source:  systemdTimerSource,
+			Message: "the timer has no settings that launchd can use to start the service",
+		})
+	case !o.hasTimer && !o.hasInstall:
+		o.warnings = append(o.warnings, ImportWarning{
+			Source:  systemdServiceSource,
+			Message: "the service has no [Install] section or timer, so launchd will only start it on demand",
+		})
+	}
+
+	return nil
+}
+
+// systemdResourceLimits maps systemd resource limit settings to their
+// launchd equivalents.
+var systemdResourceLimits = map[string]Resource{
+	"LimitCPU":     ResourceCPU,
+	"LimitCORE":    ResourceCore,
+	"LimitDATA":    ResourceData,
+	"LimitFSIZE":   ResourceFileSize,
+	"LimitMEMLOCK": ResourceMemoryLock,
+	"LimitNOFILE":  ResourceNumberOfFiles,
+	"LimitNPROC":   ResourceNumberOfProcesses,
+	"LimitRSS":     ResourceResidentSetSize,
+	"LimitSTACK":   ResourceStack,
+}
+
+// parseSystemdResourceLimit parses a single resource limit value. CPU
+// limits are time spans, while the sizes of byte limits may use the
+// K, M, G, T, P, and E suffixes, which are powers of 1024.
+func parseSystemdResourceLimit(value string, resource Resource) (int, error) {
+	if resource == ResourceCPU {
+		duration, err := parseSystemdTimeSpan(value)
+		if err != nil {
+			return 0, err
+		}
+
+		return durationToSeconds(duration), nil
+	}
+
+	multiplier := uint64(1)
+	suffixes := "KMGTPE"
+
+	if len(value) > 0 {
+		i := strings.IndexByte(suffixes, value[len(value)-1])
+		if i >= 0 {
+			if resource == ResourceNumberOfFiles || resource == ResourceNumberOfProcesses {
+				return 0, fmt.Errorf("the %s limit is not a size", resource)
+			}
+
+			multiplier = 1 << (10 * uint(i+1))
+			value = value[:len(value)-1]
+		}
+	}
+
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if n > math.MaxInt64/multiplier {
+		return 0, errors.New("the value is too large")
+	}
+
+	return int(n * multiplier), nil
+}
+
+// systemdTimeUnits maps systemd time span units to their durations.
+var systemdTimeUnits = map[string]time.Duration{
+	"us": time.Microsecond, "usec": time.Microsecond,
+	"ms": time.Millisecond, "msec": time.Millisecond,
+	"s": time.Second, "sec": time.Second, "second": time.Second, "seconds": time.Second,
+	"m": time.Minute, "min": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour, "week": 7 * 24 * time.Hour, "weeks": 7 * 24 * time.Hour,
+}
+
+// parseSystemdTimeSpan parses a systemd time span such as "90", "5min",
+// or "1h 30min". A number without a unit is a number of seconds.
+func parseSystemdTimeSpan(span string) (time.Duration, error) {
+	span = strings.TrimSpace(span)
+	if len(span) == 0 {
+		return 0, errors.New("time span is empty")
+	}
+
+	var total time.Duration
+
+	for len(span) > 0 {
+		numberEnd := strings.IndexFunc(span, func(r rune) bool {
+			return !unicode.IsDigit(r) && r != '.'
+		})
+		if numberEnd == 0 {
+			return 0, fmt.Errorf("expected a number at '%s'", span)
+		}
+		if numberEnd < 0 {
+			numberEnd = len(span)
+		}
+
+		number, err := strconv.ParseFloat(span[:numberEnd], 64)
+		if err != nil {
+			return 0, err
+		}
+		span = strings.TrimLeft(span[numberEnd:], " ")
+
+		unitEnd := strings.IndexFunc(span, func(r rune) bool {
+			return !unicode.IsLetter(r)
+		})
+		if unitEnd < 0 {
+			unitEnd = len(span)
+		}
+
+		unit := time.Second
+		if unitEnd > 0 {
+			var ok bool
+			unit, ok = systemdTimeUnits[span[:unitEnd]]
+			if !ok {
+				return 0, fmt.Errorf("unknown time unit '%s'", span[:unitEnd])
+			}
+		}
+		span = strings.TrimLeft(span[unitEnd:], " ")
+
+		total += time.Duration(number * float64(unit))
+	}
+
+	return total, nil
+}
+
+func parseSystemdBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "1", "yes", "y", "true", "t", "on":
+		return true, nil
+	case "0", "no", "n", "false", "f", "off":
+		return false, nil
+	default:
+		return false, fmt.Errorf("'%s' is not a boolean", value)
+	}
+}
+
+// splitSystemdWords splits a command line or list into words. Words may
+// be quoted using single or double quotes, and may contain C-style
+// escape sequences.
+func splitSystemdWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	var quote rune
+	inWord := false
+	escaped := false
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			switch r {
+			case 'n':
+				r = '\n'
+			case 't':
+				r = '\t'
+			case 'r':
+				r = '\r'
+			}
+			word.WriteRune(r)
+			escaped = false
+			inWord = true
+		case r == '\\':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if escaped {
+		return nil, errors.New("trailing backslash")
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+// parseEnvironmentFile parses the "NAME=VALUE" lines of a systemd
+// environment file.
+func parseEnvironmentFile(data []byte) (PlistDict, error) {
+	variables := PlistDict{}
+
+	for i, l := range strings.Split(string(data), "\n") {
+		l = strings.TrimSpace(l)
+		if len(l) == 0 || l[0] == '#' || l[0] == ';' {
+			continue
+		}
+
+		parts := strings.SplitN(l, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || len(name) == 0 {
+			return nil, fmt.Errorf("line %d is not a NAME=VALUE assignment", i+1)
+		}
+
+		value := strings.TrimSpace(parts[1])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+
+		setPlistString(&variables, name, value)
+	}
+
+	return variables, nil
+}
+
+// setPlistString sets the value of a key in the dictionary, replacing
+// any existing value.
+func setPlistString(dict *PlistDict, key string, value string) {
+	for i := range *dict {
+		if (*dict)[i].Key == key {
+			(*dict)[i].Value = PlistString(value)
+			return
+		}
+	}
+
+	*dict = append(*dict, PlistEntry{Key: key, Value: PlistString(value)})
+}
+
+// systemdCalendarShortcuts maps the shorthand calendar event names to
+// their normalized forms.
+var systemdCalendarShortcuts = map[string]string{
+	"minutely":     "*-*-* *:*:00",
+	"hourly":       "*-*-* *:00:00",
+	"daily":        "*-*-* 00:00:00",
+	"weekly":       "Mon *-*-* 00:00:00",
+	"monthly":      "*-*-01 00:00:00",
+	"yearly":       "*-01-01 00:00:00",
+	"annually":     "*-01-01 00:00:00",
+	"quarterly":    "*-01,04,07,10-01 00:00:00",
+	"semiannually": "*-01,07-01 00:00:00",
+}
+
+// systemdWeekdays maps weekday names to numbers, starting with
+// Monday as 1 and ending with Sunday as 7.
+var systemdWeekdays = map[string]int{
+	"mon": 1, "monday": 1,
+	"tue": 2, "tuesday": 2,
+	"wed": 3, "wednesday": 3,
+	"thu": 4, "thursday": 4,
+	"fri": 5, "friday": 5,
+	"sat": 6, "saturday": 6,
+	"sun": 7, "sunday": 7,
+}
+
+// parseSystemdCalendar converts a systemd calendar event (e.g.,
+// "Mon..Fri *-*-* 09:30:00") to the equivalent calendar intervals.
+// Events that specify years, seconds, or time zones are not supported.
+func parseSystemdCalendar(spec string) ([]CalendarInterval, error) {
+	normalized, isShortcut := systemdCalendarShortcuts[strings.ToLower(strings.TrimSpace(spec))]
+	if isShortcut {
+		spec = normalized
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) == 0 {
+		return nil, errors.New("calendar event is empty")
+	}
+
+	var weekdays []int
+	var err error
+
+	if unicode.IsLetter(rune(fields[0][0])) {
+		weekdays, err = parseSystemdWeekdays(fields[0])
+		if err != nil {
+			return nil, err
+		}
+		fields = fields[1:]
+	}
+
+	date := "*-*-*"
+	clock := "00:00:00"
+	hasDate := false
+	hasClock := false
+
+	for _, field := range fields {
+		switch {
+		case strings.Contains(field, ":") && !hasClock:
+			clock = field
+			hasClock = true
+		case strings.Contains(field, "-") && !hasDate && !hasClock:
+			date = field
+			hasDate = true
+		default:
+			return nil, fmt.Errorf("unsupported calendar event component '%s'", field)
+		}
+	}
+
+	if strings.Contains(date, "~") {
+		return nil, errors.New("days counted from the end of the month are not supported")
+	}
+
+	dateParts := strings.Split(date, "-")
+	switch len(dateParts) {
+	case 2:
+	case 3:
+		if dateParts[0] != "*" {
+			return nil, errors.New("specific years are not supported")
+		}
+		dateParts = dateParts[1:]
+	default:
+		return nil, fmt.Errorf("invalid date '%s'", date)
+	}
+
+	clockParts := strings.Split(clock, ":")
+	switch len(clockParts) {
+	case 2:
+	case 3:
+		seconds, err := parseSystemdCalendarValues(strings.SplitN(clockParts[2], ".", 2)[0], 0, 59)
+		if err != nil {
+			return nil, err
+		}
+		if len(seconds) != 1 || seconds[0] != 0 {
+			return nil, errors.New("seconds other than zero are not supported")
+		}
+		clockParts = clockParts[:2]
+	default:
+		return nil, fmt.Errorf("invalid time '%s'", clock)
+	}
+
+	months, err := parseSystemdCalendarValues(dateParts[0], 1, 12)
+	if err != nil {
+		return nil, err
+	}
+
+	days, err := parseSystemdCalendarValues(dateParts[1], 1, 31)
+	if err != nil {
+		return nil, err
+	}
+
+	hours, err := parseSystemdCalendarValues(clockParts[0], 0, 23)
+	if err != nil {
+		return nil, err
+	}
+
+	minutes, err := parseSystemdCalendarValues(clockParts[1], 0, 59)
+	if err != nil {
+		return nil, err
+	}
+
+	return expandCalendarIntervals(months, days, weekdays, hours, minutes)
+}
+
+// expandCalendarIntervals returns the calendar intervals that match every
+// combination of the values. A nil slice of values is a wildcard.
+func expandCalendarIntervals(months []int, days []int, weekdays []int, hours []int, minutes []int) ([]CalendarInterval, error) {
+	count := 1
+	for _, values := range [][]int{months, days, weekdays, hours, minutes} {
+		if len(values) > 0 {
+			count *= len(values)
+		}
+	}
+
+	if count > maxCalendarIntervals {
+		return nil, fmt.Errorf("calendar event expands to %d intervals, which is more than the maximum of %d",
+			count, maxCalendarIntervals)
+	}
+
+	var intervals []CalendarInterval
+
+	for _, month := range calendarValuePointers(months) {
+		for _, day := range calendarValuePointers(days) {
+			for _, weekday := range calendarValuePointers(weekdays) {
+				for _, hour := range calendarValuePointers(hours) {
+					for _, minute := range calendarValuePointers(minutes) {
+						intervals = append(intervals, CalendarInterval{
+							Minute:  minute,
+							Hour:    hour,
+							Day:     day,
+							Weekday: weekday,
+							Month:   month,
+						})
+					}
+				}
+			}
+		}
+	}
+
+	return intervals, nil
+}
+
+// parseSystemdWeekdays parses a weekday list such as "Mon..Fri,Sun". The
+// weekdays are returned using launchd's numbering, where Sunday is 0.
+// A nil slice is returned if every weekday is included.
+func parseSystemdWeekdays(spec string) ([]int, error) {
+	included := make(map[int]bool)
+
+	for _, item := range strings.Split(strings.ToLower(spec), ",") {
+		bounds := strings.SplitN(item, "..", 2)
+		if len(bounds) == 1 {
+			bounds = strings.SplitN(item, "-", 2)
+		}
+
+		first, ok := systemdWeekdays[bounds[0]]
+		if !ok {
+			return nil, fmt.Errorf("unknown weekday '%s'", bounds[0])
+		}
+
+		last := first
+		if len(bounds) == 2 {
+			last, ok = systemdWeekdays[bounds[1]]
+			if !ok {
+				return nil, fmt.Errorf("unknown weekday '%s'", bounds[1])
+			}
+		}
+
+		if last < first {
+			return nil, fmt.Errorf("invalid weekday range '%s'", item)
+		}
+
+		for day := first; day <= last; day++ {
+			included[day%7] = true
+		}
+	}
+
+	return sortedCalendarValues(included, 7), nil
+}
+
+// parseSystemdCalendarValues parses a calendar event component such as
+// "*", "5", "1,15", "9..17", or "*/15". A nil slice is returned if every
+// value between min and max is included.
+func parseSystemdCalendarValues(spec string, min int, max int) ([]int, error) {
+	included := make(map[int]bool)
+
+	for _, item := range strings.Split(spec, ",") {
+		step := 0
+		parts := strings.SplitN(item, "/", 2)
+		if len(parts) == 2 {
+			var err error
+			step, err = strconv.Atoi(parts[1])
+			if err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid repetition in '%s'", item)
+			}
+		}
+
+		first := min
+		last := max
+
+		if parts[0] != "*" {
+			bounds := strings.SplitN(parts[0], "..", 2)
+
+			var err error
+			first, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value in '%s'", item)
+			}
+
+			switch {
+			case len(bounds) == 2:
+				last, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid value in '%s'", item)
+				}
+			case step == 0:
+				last = first
+			}
+		}
+
+		if first < min || last > max || last < first {
+			return nil, fmt.Errorf("'%s' is not between %d and %d", item, min, max)
+		}
+
+		if step == 0 {
+			step = 1
+		}
+
+		for value := first; value <= last; value += step {
+			included[value] = true
+		}
+	}
+
+	return sortedCalendarValues(included, max-min+1), nil
+}
+
+// sortedCalendarValues returns the included values in ascending order,
+// or nil if all possible values are included.
+func sortedCalendarValues(included map[int]bool, numPossible int) []int {
+	if len(included) == numPossible {
+		return nil
+	}
+
+	values := make([]int, 0, len(included))
+	for value := range included {
+		values = append(values, value)
+	}
+	sort.Ints(values)
+
+	return values
+}
+
+// calendarValuePointers returns pointers to the values, or a single nil
+// pointer, which is a wildcard, if there are no values.
+func calendarValuePointers(values []int) []*int {
+	if len(values) == 0 {
+		return []*int{nil}
+	}
+
+	pointers := make([]*int, len(values))
+	for i := range values {
+		pointers[i] = intPointer(values[i])
+	}
+
+	return pointers
+}
@@ -0,0 +1,436 @@
+package launchctlutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	systemdSystemTarget = "multi-user.target"
+	systemdUserTarget   = "default.target"
+	systemdTimersTarget = "timers.target"
+)
+
+// SystemdUnits are the systemd units equivalent to a Configuration.
+type SystemdUnits struct {
+	// Service is the contents of the .service unit.
+	Service string
+
+	// Timer is the contents of the .timer unit that starts the
+	// service on a schedule. It is empty if the Configuration
+	// does not have a StartInterval or StartCalendarInterval.
+	Timer string
+
+	// UnmappedKeys are the launchd keys that have no systemd
+	// equivalent, in the order they appear in the Configuration.
+	// Keys of nested dictionaries are separated by a "." (e.g.,
+	// "KeepAlive.PathState").
+	UnmappedKeys []string
+}
+
+// ExportSystemdUnits converts a Configuration to an equivalent systemd
+// .service unit, and a .timer unit if the Configuration has a schedule.
+// The units should be named after the Configuration's label (e.g.,
+// "com.example.service" and "com.example.timer").
+//
+// Daemons are installed by the service for multi-user.target, and agents
+// for default.target, when they are loaded at boot or kept alive. Dollar
+// signs and percent signs are escaped because launchd does not expand
+// variables or specifiers.
+func ExportSystemdUnits(configuration Configuration) (SystemdUnits, error) {
+	properties, err := ConfigurationPlist(configuration)
+	if err != nil {
+		return SystemdUnits{}, err
+	}
+
+	exporter := &systemdExporter{
+		kind: configuration.GetKind(),
+	}
+
+	for _, entry := range properties {
+		exporter.exportKey(entry)
+	}
+
+	if len(exporter.execStart) == 0 {
+		return SystemdUnits{}, errors.New("the configuration does not have a Program or ProgramArguments key")
+	}
+
+	return exporter.units(configuration.GetLabel()), nil
+}
+
+type systemdExporter struct {
+	kind         Kind
+	unmappedKeys []string
+
+	program        string
+	arguments      []string
+	execStart      string
+	service        []string
+	resourceLimits map[string][2]string
+	limitOrder     []string
+	timer          []string
+	runAtLoad      bool
+	keepAlive      bool
+	disabled       bool
+}
+
+func (o *systemdExporter) unmapped(key string) {
+	o.unmappedKeys = append(o.unmappedKeys, key)
+}
+
+func (o *systemdExporter) setService(key string, value string) {
+	o.service = append(o.service, key+"="+value)
+}
+
+func (o *systemdExporter) exportKey(entry PlistEntry) {
+	switch v := entry.Value.(type) {
+	case PlistString:
+		o.exportString(entry.Key, string(v))
+	case PlistInteger:
+		o.exportInteger(entry.Key, int64(v))
+	case PlistBool:
+		o.exportBool(entry.Key, bool(v))
+	default:
+		o.exportContainer(entry)
+	}
+}
+
+func (o *systemdExporter) exportString(key string, value string) {
+	switch key {
+	case "Label":
+	case "Program":
+		o.program = value
+		o.updateExecStart()
+	case "WorkingDirectory", "RootDirectory":
+		o.setService(key, escapeSystemdSpecifiers(value))
+	case "UserName":
+		o.setService("User", value)
+	case "GroupName":
+		o.setService("Group", value)
+	case "StandardInPath":
+		o.setService("StandardInput", "file:"+escapeSystemdSpecifiers(value))
+	case "StandardOutPath":
+		o.setService("StandardOutput", "append:"+escapeSystemdSpecifiers(value))
+	case "StandardErrorPath":
+		o.setService("StandardError", "append:"+escapeSystemdSpecifiers(value))
+	default:
+		o.unmapped(key)
+	}
+}
+
+func (o *systemdExporter) exportInteger(key string, value int64) {
+	switch key {
+	case "Umask":
+		o.setService("UMask", fmt.Sprintf("%04o", value))
+	case "Nice":
+		o.setService("Nice", strconv.FormatInt(value, 10))
+	case "ThrottleInterval":
+		o.setService("RestartSec", strconv.FormatInt(value, 10))
+	case "ExitTimeOut":
+		if value == 0 {
+			o.setService("TimeoutStopSec", "infinity")
+		} else {
+			o.setService("TimeoutStopSec", strconv.FormatInt(value, 10))
+		}
+	case "StartInterval":
+		interval := strconv.FormatInt(value, 10)
+		o.timer = append(o.timer, "OnActiveSec="+interval, "OnUnitActiveSec="+interval)
+	default:
+		o.unmapped(key)
+	}
+}
+
+func (o *systemdExporter) exportBool(key string, value bool) {
+	switch key {
+	case "RunAtLoad":
+		o.runAtLoad = value
+	case "KeepAlive":
+		o.keepAlive = value
+		if value {
+			o.setService("Restart", "always")
+		}
+	case "Disabled":
+		o.disabled = value
+	case "InitGroups":
+		// systemd always initializes the supplementary groups of User.
+		if !value {
+			o.unmapped(key)
+		}
+	case "LowPriorityIO":
+		if value {
+			o.setService("IOSchedulingClass", "idle")
+		}
+	case "AbandonProcessGroup":
+		if value {
+			o.setService("KillMode", "process")
+		}
+	case "EnableGlobbing":
+		if value {
+			o.unmapped(key)
+		}
+	default:
+		o.unmapped(key)
+	}
+}
+
+func (o *systemdExporter) exportContainer(entry PlistEntry) {
+	switch entry.Key {
+	case "ProgramArguments":
+		arguments, ok := PlistDict{entry}.GetStrings(entry.Key)
+		if !ok {
+			o.unmapped(entry.Key)
+			return
+		}
+		o.arguments = arguments
+		o.updateExecStart()
+	case "EnvironmentVariables":
+		dict, ok := entry.Value.(PlistDict)
+		if !ok {
+			o.unmapped(entry.Key)
+			return
+		}
+
+		for _, variable := range dict {
+			value, ok := variable.Value.(PlistString)
+			if !ok {
+				o.unmapped(entry.Key + "." + variable.Key)
+				continue
+			}
+
+			o.setService("Environment", quoteSystemdWord(escapeSystemdSpecifiers(variable.Key+"="+string(value))))
+		}
+	case "KeepAlive":
+		o.exportKeepAlive(entry)
+	case "StartCalendarInterval":
+		intervals, err := calendarIntervalsFromPlist(entry.Value)
+		if err != nil {
+			o.unmapped(entry.Key)
+			return
+		}
+
+		for _, interval := range intervals {
+			if interval.validate() != nil {
+				o.unmapped(entry.Key)
+				return
+			}
+		}
+
+		for _, interval := range intervals {
+			for _, event := range systemdCalendarEvents(interval) {
+				o.timer = append(o.timer, "OnCalendar="+event)
+			}
+		}
+	case "SoftResourceLimits", "HardResourceLimits":
+		o.exportResourceLimits(entry)
+	default:
+		o.unmapped(entry.Key)
+	}
+}
+
+func (o *systemdExporter) exportKeepAlive(entry PlistEntry) {
+	dict, ok := entry.Value.(PlistDict)
+	if !ok {
+		o.unmapped(entry.Key)
+		return
+	}
+
+	restart := ""
+
+	for _, condition := range dict {
+		value, isBool := condition.Value.(PlistBool)
+
+		switch {
+		case condition.Key == "SuccessfulExit" && isBool && len(restart) == 0:
+			if value {
+				restart = "on-success"
+			} else {
+				restart = "on-failure"
+			}
+		case condition.Key == "Crashed" && isBool && bool(value) && len(restart) == 0:
+			restart = "on-abort"
+		default:
+			o.unmapped(entry.Key + "." + condition.Key)
+		}
+	}
+
+	if len(restart) > 0 {
+		o.setService("Restart", restart)
+	}
+}
+
+func (o *systemdExporter) exportResourceLimits(entry PlistEntry) {
+	dict, ok := entry.Value.(PlistDict)
+	if !ok {
+		o.unmapped(entry.Key)
+		return
+	}
+
+	if o.resourceLimits == nil {
+		o.resourceLimits = make(map[string][2]string)
+	}
+
+	index := 0
+	if entry.Key == "HardResourceLimits" {
+		index = 1
+	}
+
+	for _, limit := range dict {
+		name := ""
+		for systemdName, resource := range systemdResourceLimits {
+			if string(resource) == limit.Key {
+				name = systemdName
+			}
+		}
+
+		value, isInteger := limit.Value.(PlistInteger)
+		if len(name) == 0 || !isInteger {
+			o.unmapped(entry.Key + "." + limit.Key)
+			continue
+		}
+
+		limits, exists := o.resourceLimits[name]
+		if !exists {
+			o.limitOrder = append(o.limitOrder, name)
+		}
+
+		limits[index] = strconv.FormatInt(int64(value), 10)
+		o.resourceLimits[name] = limits
+	}
+}
+
+func (o *systemdExporter) updateExecStart() {
+	words := o.arguments
+	if len(o.program) > 0 {
+		words = append([]string{o.program}, o.arguments...)
+	}
+
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		word = strings.Replace(escapeSystemdSpecifiers(word), "$", "$$", -1)
+		quoted[i] = quoteSystemdWord(word)
+	}
+
+	// The "@" prefix makes the second word argv[0].
+	if len(o.program) > 0 && len(o.arguments) > 0 {
+		quoted[0] = "@" + quoted[0]
+	}
+
+	o.execStart = strings.Join(quoted, " ")
+}
+
+func (o *systemdExporter) units(label string) SystemdUnits {
+	var service bytes.Buffer
+
+	service.WriteString("[Unit]\nDescription=" + escapeSystemdSpecifiers(label) + "\n\n[Service]\nType=simple\n")
+	service.WriteString("ExecStart=" + o.execStart + "\n")
+
+	for _, line := range o.service {
+		service.WriteString(line + "\n")
+	}
+
+	for _, name := range o.limitOrder {
+		limits := o.resourceLimits[name]
+
+		// systemd cannot leave one of the limits at the system
+		// default like launchd does, so a limit is only mapped
+		// if both the soft and hard limits are set.
+		switch {
+		case len(limits[0]) == 0:
+			o.unmapped("HardResourceLimits." + string(systemdResourceLimits[name]))
+		case len(limits[1]) == 0:
+			o.unmapped("SoftResourceLimits." + string(systemdResourceLimits[name]))
+		default:
+			service.WriteString(name + "=" + limits[0] + ":" + limits[1] + "\n")
+		}
+	}
+
+	hasTimer := len(o.timer) > 0
+	if hasTimer && o.runAtLoad {
+		o.timer = append([]string{"OnActiveSec=0"}, o.timer...)
+	}
+
+	if !o.disabled && !hasTimer && (o.runAtLoad || o.keepAlive) {
+		target := systemdUserTarget
+		if o.kind.isSystemDomain() {
+			target = systemdSystemTarget
+		}
+
+		service.WriteString("\n[Install]\nWantedBy=" + target + "\n")
+	}
+
+	units := SystemdUnits{
+		Service:      service.String(),
+		UnmappedKeys: o.unmappedKeys,
+	}
+
+	if hasTimer {
+		var timer bytes.Buffer
+
+		timer.WriteString("[Unit]\nDescription=Timer for " + escapeSystemdSpecifiers(label) + "\n\n[Timer]\n")
+		for _, line := range o.timer {
+			timer.WriteString(line + "\n")
+		}
+
+		if !o.disabled {
+			timer.WriteString("\n[Install]\nWantedBy=" + systemdTimersTarget + "\n")
+		}
+
+		units.Timer = timer.String()
+	}
+
+	return units
+}
+
+// systemdCalendarEvents returns the systemd calendar events equivalent
+// to a calendar interval. launchd starts a service when either the day
+// or the weekday matches, so an interval with both is converted to two
+// events.
+func systemdCalendarEvents(interval CalendarInterval) []string {
+	component := func(value *int, format string) string {
+		if value == nil {
+			return "*"
+		}
+		return fmt.Sprintf(format, *value)
+	}
+
+	clock := component(interval.Hour, "%02d") + ":" + component(interval.Minute, "%02d") + ":00"
+	month := component(interval.Month, "%02d")
+
+	if interval.Weekday == nil {
+		return []string{"*-" + month + "-" + component(interval.Day, "%02d") + " " + clock}
+	}
+
+	weekdays := []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+	weekdayEvent := weekdays[*interval.Weekday%7] + " *-" + month + "-* " + clock
+
+	if interval.Day == nil {
+		return []string{weekdayEvent}
+	}
+
+	return []string{"*-" + month + "-" + component(interval.Day, "%02d") + " " + clock, weekdayEvent}
+}
+
+// escapeSystemdSpecifiers escapes percent signs so that systemd does not
+// treat them as unit specifiers.
+func escapeSystemdSpecifiers(s string) string {
+	return strings.Replace(s, "%", "%%", -1)
+}
+
+// quoteSystemdWord quotes a word if it contains characters that systemd
+// would otherwise interpret.
+func quoteSystemdWord(word string) string {
+	if len(word) > 0 && !strings.ContainsAny(word, " \t\n\r\"'\\;") {
+		return word
+	}
+
+	word = strings.Replace(word, `\`, `\\`, -1)
+	word = strings.Replace(word, `"`, `\"`, -1)
+	word = strings.Replace(word, "\n", `\n`, -1)
+	word = strings.Replace(word, "\t", `\t`, -1)
+	word = strings.Replace(word, "\r", `\r`, -1)
+
+	return `"` + word + `"`
+}
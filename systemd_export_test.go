@@ -0,0 +1,190 @@
+package launchctlutil
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportSystemdUnits(t *testing.T) {
+	config, err := NewConfigurationBuilder().
+		SetKind(Daemon).
+		SetLabel("com.example").
+		SetProgram("/usr/local/bin/example").
+		SetCommand("example").
+		AddArgument("--greeting").
+		AddArgument("hello $USER at 100%").
+		AddEnvironmentVariable("MODE", "prod mode").
+		SetUserName("example").
+		SetWorkingDirectory("/var/lib/example").
+		SetStandardOutPath("/var/log/example.log").
+		SetKeepAliveConditions(KeepAliveConditions{
+			SuccessfulExit: boolPointer(false),
+			NetworkState:   boolPointer(true),
+		}).
+		SetThrottleInterval(5*time.Second).
+		SetSoftResourceLimit(ResourceNumberOfFiles, 8192).
+		SetHardResourceLimit(ResourceNumberOfFiles, 65536).
+		SetRunAtLoad(true).
+		AddWatchPath("/etc/example").
+		Build()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	units, err := ExportSystemdUnits(config)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	exp := `[Unit]
+Description=com.example
+
+[Service]
+Type=simple
+ExecStart=@/usr/local/bin/example example --greeting "hello $$USER at 100%%"
+Environment="MODE=prod mode"
+User=example
+WorkingDirectory=/var/lib/example
+StandardOutput=append:/var/log/example.log
+RestartSec=5
+Restart=on-failure
+LimitNOFILE=8192:65536
+
+[Install]
+WantedBy=multi-user.target
+`
+
+	if units.Service != exp {
+		t.Fatalf("expected service:\n%s\ngot:\n%s", exp, units.Service)
+	}
+
+	if len(units.Timer) > 0 {
+		t.Fatalf("timer should be empty - got:\n%s", units.Timer)
+	}
+
+	unmapped := strings.Join(units.UnmappedKeys, ",")
+	if unmapped != "WatchPaths,KeepAlive.NetworkState" {
+		t.Fatalf("unexpected unmapped keys - got '%s'", unmapped)
+	}
+}
+
+func TestExportSystemdUnitsTimer(t *testing.T) {
+	config, err := NewConfigurationBuilder().
+		SetLabel("com.example.backup").
+		SetCommand("/usr/bin/backup").
+		SetStartIntervalDuration(time.Hour).
+		AddStartCalendarInterval(CalendarInterval{Hour: intPointer(3), Minute: intPointer(30)}).
+		AddStartCalendarInterval(CalendarInterval{Weekday: intPointer(0), Hour: intPointer(0)}).
+		Build()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	units, err := ExportSystemdUnits(config)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	exp := `[Unit]
+Description=Timer for com.example.backup
+
+[Timer]
+OnActiveSec=3600
+OnUnitActiveSec=3600
+OnCalendar=*-*-* 03:30:00
+OnCalendar=Sun *-*-* 00:*:00
+
+[Install]
+WantedBy=timers.target
+`
+
+	if units.Timer != exp {
+		t.Fatalf("expected timer:\n%s\ngot:\n%s", exp, units.Timer)
+	}
+
+	if strings.Contains(units.Service, "[Install]") {
+		t.Fatalf("a service started by a timer should not be installed - got:\n%s", units.Service)
+	}
+
+	// The timer should convert back to the same schedule.
+	imported, warnings, err := ImportSystemdUnit([]byte(units.Service), SystemdImportOptions{
+		Label: config.GetLabel(),
+		Timer: []byte(units.Timer),
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, warning := range warnings {
+		if warning.Setting != "[Unit] Description" {
+			t.Fatalf("unexpected warning - %s", warning)
+		}
+	}
+
+	if imported.GetContents() != config.GetContents() {
+		t.Fatalf("expected round trip to produce:\n%s\ngot:\n%s", config.GetContents(), imported.GetContents())
+	}
+}
+
+func TestSystemdCalendarEventsDayAndWeekday(t *testing.T) {
+	events := systemdCalendarEvents(CalendarInterval{
+		Day:     intPointer(1),
+		Weekday: intPointer(7),
+		Month:   intPointer(6),
+		Minute:  intPointer(5),
+	})
+
+	exp := "*-06-01 *:05:00|Sun *-06-* *:05:00"
+	if strings.Join(events, "|") != exp {
+		t.Fatalf("expected events '%s' - got '%s'", exp, strings.Join(events, "|"))
+	}
+}
+
+func TestExportSystemdUnitsInvalidCalendarInterval(t *testing.T) {
+	// Configurations created outside of this package may contain
+	// calendar intervals that are out of range.
+	config := &configuration{
+		label: "com.testing",
+		contents: encodePlistDocument(PlistDict{
+			{Key: "Label", Value: PlistString("com.testing")},
+			{Key: "Program", Value: PlistString("/bin/echo")},
+			{Key: "StartCalendarInterval", Value: PlistDict{{Key: "Weekday", Value: PlistInteger(-1)}}},
+		}),
+	}
+
+	units, err := ExportSystemdUnits(config)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(units.UnmappedKeys) != 1 || units.UnmappedKeys[0] != "StartCalendarInterval" {
+		t.Fatalf("the invalid calendar interval should be unmapped - got %v", units.UnmappedKeys)
+	}
+}
+
+func TestExportSystemdUnitsOneSidedResourceLimits(t *testing.T) {
+	config, err := NewConfigurationBuilder().
+		SetLabel("com.testing").
+		SetCommand("/bin/echo").
+		SetSoftResourceLimit(ResourceNumberOfFiles, 1024).
+		SetHardResourceLimit(ResourceCore, 0).
+		Build()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	units, err := ExportSystemdUnits(config)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if strings.Contains(units.Service, "Limit") {
+		t.Fatalf("one-sided resource limits should not be exported - got:\n%s", units.Service)
+	}
+
+	exp := "SoftResourceLimits.NumberOfFiles|HardResourceLimits.Core"
+	if strings.Join(units.UnmappedKeys, "|") != exp {
+		t.Fatalf("expected unmapped keys '%s' - got %v", exp, units.UnmappedKeys)
+	}
+}
@@ -0,0 +1,324 @@
+package launchctlutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSystemdService = `[Unit]
+Description=Example daemon
+
+[Service]
+Type=simple
+ExecStart=/usr/local/bin/example --config "/etc/example/config file.yml" \
+    --verbose
+Environment="GREETING=hello world" MODE=prod
+EnvironmentFile=%s
+WorkingDirectory=/var/lib/example
+User=example
+Group=staff
+Restart=on-failure
+RestartSec=5
+LimitNOFILE=8192:65536
+StandardOutput=append:/var/log/example.log
+PrivateTmp=yes
+
+[Install]
+WantedBy=multi-user.target
+`
+
+const testSystemdTimer = `[Timer]
+OnCalendar=Mon..Fri *-*-* 09:30
+OnUnitActiveSec=1h
+AccuracySec=1min
+
+[Install]
+WantedBy=timers.target
+`
+
+func TestImportSystemdUnit(t *testing.T) {
+	envFile, err := ioutil.TempFile("", "launchctlutil-test-")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(envFile.Name())
+
+	envFile.WriteString("# Comment\nMODE=debug\nTOKEN='abc'\n")
+	envFile.Close()
+
+	service := strings.Replace(testSystemdService, "%s", envFile.Name(), 1)
+
+	config, warnings, err := ImportSystemdUnit([]byte(service), SystemdImportOptions{
+		Label: "com.example",
+		Kind:  Daemon,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	value, err := DecodePlist([]byte(config.GetContents()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	dict := value.(PlistDict)
+
+	args, _ := dict.GetStrings("ProgramArguments")
+	expArgs := []string{"/usr/local/bin/example", "--config", "/etc/example/config file.yml", "--verbose"}
+	if strings.Join(args, "|") != strings.Join(expArgs, "|") {
+		t.Fatalf("unexpected program arguments - got %q", args)
+	}
+
+	environment, _ := dict.Get("EnvironmentVariables")
+	envDict := environment.(PlistDict)
+	for name, exp := range map[string]string{"GREETING": "hello world", "MODE": "debug", "TOKEN": "abc"} {
+		v, _ := envDict.GetString(name)
+		if v != exp {
+			t.Fatalf("environment variable '%s' should be '%s' - got '%s'", name, exp, v)
+		}
+	}
+
+	strs := map[string]string{
+		"WorkingDirectory":  "/var/lib/example",
+		"UserName":          "example",
+		"GroupName":         "staff",
+		"StandardOutPath":   "/var/log/example.log",
+		"StandardErrorPath": "/var/log/example.log",
+	}
+	for key, exp := range strs {
+		v, _ := dict.GetString(key)
+		if v != exp {
+			t.Fatalf("%s should be '%s' - got '%s'", key, exp, v)
+		}
+	}
+
+	throttle, _ := dict.GetInteger("ThrottleInterval")
+	if throttle != 5 {
+		t.Fatalf("ThrottleInterval should be 5 - got %d", throttle)
+	}
+
+	runAtLoad, _ := dict.GetBool("RunAtLoad")
+	if !runAtLoad {
+		t.Fatal("RunAtLoad should be true for a service with an [Install] section")
+	}
+
+	keepAlive, _ := dict.Get("KeepAlive")
+	successfulExit, _ := keepAlive.(PlistDict).GetBool("SuccessfulExit")
+	if successfulExit {
+		t.Fatal("KeepAlive.SuccessfulExit should be false")
+	}
+
+	hardLimits, _ := dict.Get("HardResourceLimits")
+	files, _ := hardLimits.(PlistDict).GetInteger("NumberOfFiles")
+	if files != 65536 {
+		t.Fatalf("hard NumberOfFiles limit should be 65536 - got %d", files)
+	}
+
+	var settings []string
+	for _, warning := range warnings {
+		settings = append(settings, warning.Setting)
+	}
+
+	for _, exp := range []string{"[Unit] Description", "[Service] PrivateTmp", "[Service] EnvironmentFile"} {
+		if !strings.Contains(strings.Join(settings, "\n"), exp) {
+			t.Fatalf("expected a warning for %s - got %q", exp, settings)
+		}
+	}
+}
+
+func TestReadSystemdUnitFileWithTimer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "launchctlutil-test-")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	service := "[Service]\nType=oneshot\nExecStart=/usr/bin/backup\n"
+	ioutil.WriteFile(path.Join(dir, "backup.service"), []byte(service), 0600)
+	ioutil.WriteFile(path.Join(dir, "backup.timer"), []byte(testSystemdTimer), 0600)
+
+	config, warnings, err := ReadSystemdUnitFile(path.Join(dir, "backup.service"), SystemdImportOptions{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if config.GetLabel() != "backup" {
+		t.Fatalf("label should be 'backup' - got '%s'", config.GetLabel())
+	}
+
+	discovered := &DiscoveredService{}
+	value, _ := DecodePlist([]byte(config.GetContents()))
+	err = discovered.populate(value)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if discovered.Schedule.RunAtLoad {
+		t.Fatal("RunAtLoad should not be set for a timer without OnBootSec")
+	}
+
+	if discovered.Schedule.StartInterval != 3600 {
+		t.Fatalf("StartInterval should be 3600 - got %d", discovered.Schedule.StartInterval)
+	}
+
+	intervals := discovered.Schedule.StartCalendarIntervals
+	if len(intervals) != 5 {
+		t.Fatalf("expected 5 calendar intervals - got %d", len(intervals))
+	}
+
+	if *intervals[0].Weekday != 1 || *intervals[4].Weekday != 5 || *intervals[0].Hour != 9 || *intervals[0].Minute != 30 {
+		t.Fatalf("unexpected calendar interval %+v", intervals[0])
+	}
+
+	if len(warnings) != 1 || warnings[0].Setting != "[Timer] AccuracySec" || warnings[0].Source != "timer" {
+		t.Fatalf("expected a single AccuracySec warning - got %v", warnings)
+	}
+}
+
+func TestImportSystemdUnitActivationDelay(t *testing.T) {
+	service := []byte("[Service]\nExecStart=/usr/bin/backup\n")
+
+	tests := []struct {
+		timer     string
+		runAtLoad bool
+		warnings  int
+	}{
+		{timer: "[Timer]\nOnBootSec=0\nOnCalendar=daily\n", runAtLoad: true},
+		{timer: "[Timer]\nOnBootSec=1h\nOnUnitActiveSec=1h\n"},
+		{timer: "[Timer]\nOnActiveSec=5min\nOnUnitActiveSec=1h\n", runAtLoad: true, warnings: 1},
+	}
+
+	for _, test := range tests {
+		config, warnings, err := ImportSystemdUnit(service, SystemdImportOptions{
+			Label: "com.testing",
+			Timer: []byte(test.timer),
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		dict, _ := ConfigurationPlist(config)
+		runAtLoad, _ := dict.GetBool("RunAtLoad")
+		if runAtLoad != test.runAtLoad {
+			t.Fatalf("RunAtLoad should be %t for timer %q", test.runAtLoad, test.timer)
+		}
+
+		if len(warnings) != test.warnings {
+			t.Fatalf("expected %d warnings for timer %q - got %v", test.warnings, test.timer, warnings)
+		}
+	}
+}
+
+func TestImportSystemdUnitSoftLimitExceedsHardLimit(t *testing.T) {
+	config, warnings, err := ImportSystemdUnit([]byte("[Service]\nExecStart=/bin/echo\nLimitNOFILE=4096:1024\n\n[Install]\nWantedBy=multi-user.target\n"),
+		SystemdImportOptions{
+			Label: "com.testing",
+		})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(warnings) != 1 || warnings[0].Setting != "[Service] LimitNOFILE" {
+		t.Fatalf("expected a single LimitNOFILE warning - got %v", warnings)
+	}
+
+	dict, _ := ConfigurationPlist(config)
+	_, hasSoft := dict.Get("SoftResourceLimits")
+	_, hasHard := dict.Get("HardResourceLimits")
+	if hasSoft || hasHard {
+		t.Fatal("the resource limits should not be set")
+	}
+}
+
+func TestImportSystemdUnitNoExecStart(t *testing.T) {
+	_, _, err := ImportSystemdUnit([]byte("[Service]\nType=simple\n"), SystemdImportOptions{
+		Label: "com.testing",
+	})
+	if err == nil {
+		t.Fatal("expected an error for a service without ExecStart")
+	}
+}
+
+func TestParseSystemdCalendar(t *testing.T) {
+	intervals, err := parseSystemdCalendar("daily")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(intervals) != 1 || *intervals[0].Hour != 0 || *intervals[0].Minute != 0 || intervals[0].Day != nil {
+		t.Fatalf("unexpected intervals for daily - got %+v", intervals)
+	}
+
+	intervals, err = parseSystemdCalendar("*:0/15")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(intervals) != 4 || *intervals[3].Minute != 45 || intervals[0].Hour != nil {
+		t.Fatalf("unexpected intervals for *:0/15 - got %d", len(intervals))
+	}
+
+	intervals, err = parseSystemdCalendar("Sat,Sun *-12-25 08:00")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(intervals) != 2 || *intervals[0].Weekday != 0 || *intervals[1].Weekday != 6 || *intervals[0].Month != 12 {
+		t.Fatalf("unexpected intervals for weekends on Christmas - got %+v", intervals)
+	}
+
+	for _, spec := range []string{"2020-01-01", "*:*:30", "daily UTC", "*-*~01", "Funday"} {
+		_, err := parseSystemdCalendar(spec)
+		if err == nil {
+			t.Fatalf("expected an error for '%s'", spec)
+		}
+	}
+}
+
+func TestParseSystemdTimeSpan(t *testing.T) {
+	cases := map[string]time.Duration{
+		"90":         90 * time.Second,
+		"5min":       5 * time.Minute,
+		"1h 30min":   90 * time.Minute,
+		"2d":         48 * time.Hour,
+		"500ms":      500 * time.Millisecond,
+		"1min30s":    90 * time.Second,
+		" 10 second": 10 * time.Second,
+	}
+
+	for span, exp := range cases {
+		d, err := parseSystemdTimeSpan(span)
+		if err != nil {
+			t.Fatalf("failed to parse '%s' - %s", span, err.Error())
+		}
+
+		if d != exp {
+			t.Fatalf("'%s' should be %s - got %s", span, exp, d)
+		}
+	}
+
+	_, err := parseSystemdTimeSpan("5 fortnights")
+	if err == nil {
+		t.Fatal("expected an error for an unknown unit")
+	}
+}
+
+func TestSplitSystemdWords(t *testing.T) {
+	words, err := splitSystemdWords(`/bin/echo "a b" 'c\td' e\ f`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	exp := []string{"/bin/echo", "a b", "c\td", "e f"}
+	if strings.Join(words, "|") != strings.Join(exp, "|") {
+		t.Fatalf("expected %q - got %q", exp, words)
+	}
+
+	_, err = splitSystemdWords(`"unterminated`)
+	if err == nil {
+		t.Fatal("expected an error for an unterminated quote")
+	}
+}
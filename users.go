@@ -0,0 +1,364 @@
+package launchctlutil
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultDscl = "dscl"
+
+	// firstLoginUID is the first UID macOS assigns to user accounts.
+	firstLoginUID       = 501
+	systemAccountPrefix = "_"
+	launchctlAsUser     = "asuser"
+
+	// userWriteScript creates the directory named by its first argument
+	// and replaces the file named by its second argument with stdin.
+	userWriteScript = `umask 077 && /bin/mkdir -p -m 0755 "$1" && /bin/rm -f "$2" && /bin/cat > "$2"`
+)
+
+var (
+	// DsclPath is the path to the dscl CLI application. It is used
+	// to enumerate user accounts.
+	DsclPath = defaultDscl
+)
+
+// User is a user account whose launchd domain agents can be loaded into.
+type User struct {
+	Username string
+	Uid      int
+	Gid      int
+	HomeDir  string
+}
+
+// Domain returns the user's launchd GUI domain (e.g., "gui/501").
+func (o User) Domain() string {
+	return guiDomain + strconv.Itoa(o.Uid)
+}
+
+// Users returns the user accounts on the system. System accounts (e.g.,
+// those whose names start with an underscore) are not included.
+func Users() ([]User, error) {
+	output, err := exec.Command(DsclPath, ".", "-list", "/Users", "UniqueID").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users - %s - output: %s", err.Error(), output)
+	}
+
+	usernames, err := parseDsclUsers(string(output))
+	if err != nil {
+		return nil, err
+	}
+
+	var users []User
+
+	for _, username := range usernames {
+		u, err := LookupUser(username)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, u)
+	}
+
+	return users, nil
+}
+
+// LookupUser looks up a user account by username.
+func LookupUser(username string) (User, error) {
+	u, err := user.Lookup(username)
+	if err != nil {
+		return User{}, err
+	}
+
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return User{}, fmt.Errorf("failed to parse UID of user '%s' - %s", username, err.Error())
+	}
+
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return User{}, fmt.Errorf("failed to parse GID of user '%s' - %s", username, err.Error())
+	}
+
+	return User{
+		Username: u.Username,
+		Uid:      uid,
+		Gid:      gid,
+		HomeDir:  u.HomeDir,
+	}, nil
+}
+
+// InstallForUser installs the provided UserAgent or GlobalAgent Configuration
+// and loads it into the specified user's launchd domain. UserAgent
+// configurations are written to the user's LaunchAgents directory, which
+// is created if it does not exist. The file is written as the user so that
+// it is owned by the user, and so that symbolic links in the user's home
+// directory cannot redirect the write. Root privileges are required.
+func InstallForUser(configuration Configuration, username string) error {
+	kind := configuration.GetKind()
+	if kind != UserAgent && kind != GlobalAgent {
+		return fmt.Errorf("%s configurations cannot be installed for a specific user", kind)
+	}
+
+	u, runner, err := lookupUserWithPrivileges("install", username)
+	if err != nil {
+		return err
+	}
+
+	return installForUser(runner, configuration, u)
+}
+
+func installForUser(runner Runner, configuration Configuration, u User) error {
+	kind := configuration.GetKind()
+
+	configPath, err := configFilePathForUser(configuration.GetLabel(), kind, u)
+	if err != nil {
+		return err
+	}
+
+	// Try to remove the agent first because it may already exist.
+	// Ignore errors because this may create false positives.
+	runAsUser(runner, u, "unload", configPath)
+
+	if kind == UserAgent {
+		err = writeUserFile(runner, u, configPath, []byte(configuration.GetContents()))
+	} else {
+		err = runner.WriteFile(configPath, []byte(configuration.GetContents()), 0600)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = runAsUser(runner, u, "load", configPath)
+	if err != nil {
+		return err
+	}
+
+	entries, err := listAsUser(runner, u)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.Label == configuration.GetLabel() {
+			return nil
+		}
+	}
+
+	// Try to remove the config file if the installation fails.
+	// Ignore errors because this may create false positives.
+	if kind == UserAgent {
+		removeUserFile(runner, u, configPath)
+	}
+
+	return fmt.Errorf("an unknown error occurred installing the launchctl config for user '%s'", u.Username)
+}
+
+// UninstallForUser unloads the specified agent from the user's launchd
+// domain. UserAgent configuration files are removed from the user's
+// LaunchAgents directory as the user. GlobalAgent configuration files
+// are left in place because other users may still load them. Root
+// privileges are required.
+func UninstallForUser(label string, kind Kind, username string) (UninstallResult, error) {
+	if kind != UserAgent && kind != GlobalAgent {
+		return UninstallResult{}, fmt.Errorf("%s configurations cannot be uninstalled for a specific user", kind)
+	}
+
+	u, runner, err := lookupUserWithPrivileges("uninstall", username)
+	if err != nil {
+		return UninstallResult{}, err
+	}
+
+	return uninstallForUser(runner, label, kind, u)
+}
+
+func uninstallForUser(runner Runner, label string, kind Kind, u User) (UninstallResult, error) {
+	configPath, err := configFilePathForUser(label, kind, u)
+	if err != nil {
+		return UninstallResult{}, err
+	}
+
+	result := UninstallResult{
+		FilePath: configPath,
+	}
+
+	_, statErr := os.Lstat(configPath)
+	hasFile := statErr == nil
+
+	entries, err := listAsUser(runner, u)
+	if err != nil {
+		return result, err
+	}
+
+	for _, entry := range entries {
+		if entry.Label != label {
+			continue
+		}
+
+		if hasFile {
+			_, err = runAsUser(runner, u, "unload", configPath)
+		} else {
+			_, err = runAsUser(runner, u, "remove", label)
+		}
+		if err != nil {
+			return result, err
+		}
+
+		result.Unloaded = true
+		break
+	}
+
+	if hasFile && kind == UserAgent {
+		err = removeUserFile(runner, u, configPath)
+		if err != nil {
+			return result, err
+		}
+
+		result.FileRemoved = true
+	}
+
+	return result, nil
+}
+
+// StartForUser starts the specified agent in the user's launchd domain.
+// Root privileges are required.
+func StartForUser(label string, username string) error {
+	u, runner, err := lookupUserWithPrivileges("start", username)
+	if err != nil {
+		return err
+	}
+
+	_, err = runAsUser(runner, u, "start", label)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// StopForUser stops the specified agent in the user's launchd domain.
+// Root privileges are required.
+func StopForUser(label string, username string) error {
+	u, runner, err := lookupUserWithPrivileges("stop", username)
+	if err != nil {
+		return err
+	}
+
+	_, err = runAsUser(runner, u, "stop", label)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ListForUser returns the launchd services that are loaded in the
+// specified user's domain. Root privileges are required.
+func ListForUser(username string) ([]ListEntry, error) {
+	u, runner, err := lookupUserWithPrivileges("list", username)
+	if err != nil {
+		return nil, err
+	}
+
+	return listAsUser(runner, u)
+}
+
+func lookupUserWithPrivileges(operation string, username string) (User, Runner, error) {
+	u, err := LookupUser(username)
+	if err != nil {
+		return User{}, nil, err
+	}
+
+	runner, err := requirePrivilegesInDomain(operation, u.Domain(), true, os.Geteuid())
+	if err != nil {
+		return User{}, nil, err
+	}
+
+	return u, runner, nil
+}
+
+func configFilePathForUser(label string, kind Kind, u User) (string, error) {
+	if kind == UserAgent {
+		if len(u.HomeDir) == 0 {
+			return "", fmt.Errorf("failed to determine home directory of user '%s'", u.Username)
+		}
+
+		return userAgentsDirectory(u.HomeDir) + "/" + label + plistFileSuffix, nil
+	}
+
+	return (&configuration{label: label, kind: kind}).GetFilePath()
+}
+
+// writeUserFile writes data to a file in a directory that the user
+// controls. The file and any missing directories are created by the user.
+func writeUserFile(runner Runner, u User, filePath string, data []byte) error {
+	output, err := runner.ExecuteAsUser(u.Uid, u.Gid, data,
+		shExePath, "-c", userWriteScript, shExePath, path.Dir(filePath), filePath)
+	if err != nil {
+		return fmt.Errorf("failed to write '%s' as user '%s' - %s - output: %s",
+			filePath, u.Username, err.Error(), output)
+	}
+
+	return nil
+}
+
+// removeUserFile removes a file in a directory that the user controls
+// as the user.
+func removeUserFile(runner Runner, u User, filePath string) error {
+	output, err := runner.ExecuteAsUser(u.Uid, u.Gid, nil, rmExePath, "-f", filePath)
+	if err != nil {
+		return fmt.Errorf("failed to remove '%s' as user '%s' - %s - output: %s",
+			filePath, u.Username, err.Error(), output)
+	}
+
+	return nil
+}
+
+func runAsUser(runner Runner, u User, args ...string) (string, error) {
+	return run(runner, append([]string{launchctlAsUser, strconv.Itoa(u.Uid), ExePath}, args...)...)
+}
+
+func listAsUser(runner Runner, u User) ([]ListEntry, error) {
+	output, err := runAsUser(runner, u, "list")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseList(output)
+}
+
+// parseDsclUsers parses the output of "dscl . -list /Users UniqueID"
+// and returns the usernames of user accounts.
+func parseDsclUsers(output string) ([]string, error) {
+	var usernames []string
+
+	for _, l := range strings.Split(output, "\n") {
+		fields := strings.Fields(l)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("unexpected dscl output line: '%s'", l)
+		}
+
+		uid, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse UID of user '%s' - %s", fields[0], err.Error())
+		}
+
+		if uid < firstLoginUID || strings.HasPrefix(fields[0], systemAccountPrefix) {
+			continue
+		}
+
+		usernames = append(usernames, fields[0])
+	}
+
+	return usernames, nil
+}
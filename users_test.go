@@ -0,0 +1,131 @@
+package launchctlutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestParseDsclUsers(t *testing.T) {
+	usernames, err := parseDsclUsers("_amavisd                 83\n" +
+		"daemon                   1\n" +
+		"nobody                   -2\n" +
+		"root                     0\n" +
+		"stephen                  501\n" +
+		"guest                    502\n")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(usernames) != 2 || usernames[0] != "stephen" || usernames[1] != "guest" {
+		t.Fatalf("unexpected usernames - got %v", usernames)
+	}
+}
+
+func TestConfigFilePathForUser(t *testing.T) {
+	configPath, err := configFilePathForUser("com.testing", UserAgent, User{
+		Username: "stephen",
+		HomeDir:  "/Users/stephen",
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	exp := "/Users/stephen/Library/LaunchAgents/com.testing.plist"
+	if configPath != exp {
+		t.Fatalf("config path should be '%s' - got '%s'", exp, configPath)
+	}
+}
+
+func TestInstallForUser(t *testing.T) {
+	home, err := ioutil.TempDir("", "launchctlutil-test-")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(home)
+
+	u := User{Username: "stephen", Uid: 501, Gid: 20, HomeDir: home}
+
+	config, err := NewConfigurationBuilder().
+		SetLabel("com.testing").
+		SetCommand("/bin/true").
+		Build()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	runner := &testRunner{loaded: make(map[string]bool)}
+
+	// The LaunchAgents directory does not exist yet.
+	err = installForUser(runner, config, u)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	configPath := path.Join(home, "Library", "LaunchAgents", "com.testing.plist")
+
+	contents, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if string(contents) != config.GetContents() {
+		t.Fatalf("unexpected file contents - got:\n%s", contents)
+	}
+
+	exp := []string{"unload " + configPath, "as 501: sh", "load " + configPath, "list"}
+	if strings.Join(runner.commands, ", ") != strings.Join(exp, ", ") {
+		t.Fatalf("expected commands %q - got %q", exp, runner.commands)
+	}
+
+	result, err := uninstallForUser(runner, "com.testing", UserAgent, u)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !result.Unloaded || !result.FileRemoved {
+		t.Fatalf("the agent should be unloaded and removed - got %+v", result)
+	}
+
+	_, err = os.Stat(configPath)
+	if !os.IsNotExist(err) {
+		t.Fatalf("the configuration file should be removed - got %v", err)
+	}
+
+	if runner.commands[len(runner.commands)-1] != "as 501: rm" {
+		t.Fatalf("the file should be removed as the user - got %q", runner.commands)
+	}
+}
+
+func TestInstallForUserNotLoaded(t *testing.T) {
+	home, err := ioutil.TempDir("", "launchctlutil-test-")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(home)
+
+	u := User{Username: "stephen", Uid: 501, Gid: 20, HomeDir: home}
+
+	config, err := NewConfigurationBuilder().
+		SetLabel("com.testing").
+		SetCommand("/bin/true").
+		Build()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// Loading is a no-op, so the agent is never listed.
+	runner := &testRunner{loaded: make(map[string]bool), ignoreLoad: true}
+
+	err = installForUser(runner, config, u)
+	if err == nil {
+		t.Fatal("expected an error when the agent is not loaded")
+	}
+
+	_, err = os.Stat(path.Join(home, "Library", "LaunchAgents", "com.testing.plist"))
+	if !os.IsNotExist(err) {
+		t.Fatalf("the configuration file should be removed after a failure - got %v", err)
+	}
+}